@@ -0,0 +1,50 @@
+package consistency
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	cache "github.com/luxpo/time-go2nd/cache/redis"
+)
+
+var _ Strategy = (*DoubleDelete)(nil)
+
+// DoubleDelete 延迟双删：更新数据库之后马上删一次缓存，过 delay 之后再删一次。
+// 第二次删除是为了删掉并发的读请求在这期间用旧数据回填的缓存，
+// delay 需要比一次"读数据库 + 写缓存"的时间长
+type DoubleDelete struct {
+	c         cache.Cache
+	scheduler *Scheduler
+	delay     time.Duration
+}
+
+// NewDoubleDelete scheduler 可以在多个策略之间共享
+func NewDoubleDelete(c cache.Cache, scheduler *Scheduler, delay time.Duration) *DoubleDelete {
+	return &DoubleDelete{
+		c:         c,
+		scheduler: scheduler,
+		delay:     delay,
+	}
+}
+
+func (d *DoubleDelete) Update(ctx context.Context, key string, update UpdateFunc) error {
+	if _, _, err := update(ctx); err != nil {
+		return err
+	}
+	// 第一次删除失败了也要延迟删除，至少保证最终一致
+	scheduleErr := d.scheduler.Schedule(d.delay, func(ctx context.Context) error {
+		if err := d.c.Delete(ctx, key); err != nil {
+			return fmt.Errorf("consistency: 延迟删除失败, key: %s, %w", key, err)
+		}
+		return nil
+	})
+	if err := d.c.Delete(ctx, key); err != nil {
+		return err
+	}
+	if scheduleErr != nil {
+		// 第一次删除成功了，但是没有延迟删除，并发的读请求依旧可能回填旧数据
+		return fmt.Errorf("consistency: 无法延迟删除, key: %s, %w", key, scheduleErr)
+	}
+	return nil
+}
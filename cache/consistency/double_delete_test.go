@@ -0,0 +1,65 @@
+package consistency
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	v3 "github.com/luxpo/time-go2nd/cache/local/v3"
+	cache "github.com/luxpo/time-go2nd/cache/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoubleDelete_Update(t *testing.T) {
+	lc := v3.NewLocalCache(time.Minute)
+	defer lc.Close()
+	s := NewScheduler()
+	defer s.Close()
+	d := NewDoubleDelete(lc, s, time.Millisecond*50)
+	ctx := context.Background()
+
+	require.NoError(t, lc.Set(ctx, "user:1", "old", time.Minute))
+	err := d.Update(ctx, "user:1", func(ctx context.Context) (any, int64, error) {
+		return "new", 1, nil
+	})
+	require.NoError(t, err)
+	_, err = lc.Get(ctx, "user:1")
+	assert.ErrorIs(t, err, cache.ErrKeyNotFound)
+
+	// 模拟并发的读请求用旧数据回填
+	require.NoError(t, lc.Set(ctx, "user:1", "old", time.Minute))
+	assert.Eventually(t, func() bool {
+		_, err := lc.Get(ctx, "user:1")
+		return errors.Is(err, cache.ErrKeyNotFound)
+	}, time.Second, time.Millisecond*10)
+
+	// 更新数据库失败，缓存不动
+	require.NoError(t, lc.Set(ctx, "user:2", "old", time.Minute))
+	err = d.Update(ctx, "user:2", func(ctx context.Context) (any, int64, error) {
+		return nil, 0, errors.New("db error")
+	})
+	assert.EqualError(t, err, "db error")
+	val, err := lc.Get(ctx, "user:2")
+	require.NoError(t, err)
+	assert.Equal(t, "old", val)
+}
+
+func TestDoubleDelete_UpdateAfterSchedulerClosed(t *testing.T) {
+	lc := v3.NewLocalCache(time.Minute)
+	defer lc.Close()
+	s := NewScheduler()
+	require.NoError(t, s.Close())
+	d := NewDoubleDelete(lc, s, time.Millisecond*50)
+	ctx := context.Background()
+
+	// 第一次删除依旧会执行，但是要告诉调用方没有延迟删除
+	require.NoError(t, lc.Set(ctx, "user:1", "old", time.Minute))
+	err := d.Update(ctx, "user:1", func(ctx context.Context) (any, int64, error) {
+		return "new", 1, nil
+	})
+	assert.ErrorIs(t, err, ErrSchedulerClosed)
+	_, err = lc.Get(ctx, "user:1")
+	assert.ErrorIs(t, err, cache.ErrKeyNotFound)
+}
@@ -0,0 +1,21 @@
+-- KEYS[1] 保存值和版本号的 hash
+-- ARGV[1] 值，ARGV[2] 版本号，ARGV[3] 过期时间（毫秒），0 表示不过期
+-- 版本号是非负整数，按照字符串比较，避免超过 2^53 之后 lua 的数字丢失精度
+local function newer(a, b)
+    if #a ~= #b then
+        return #a > #b
+    end
+    return a > b
+end
+
+local cur = redis.call("hget", KEYS[1], "ver")
+if cur and not newer(ARGV[2], cur) then
+    return 0
+end
+redis.call("hset", KEYS[1], "val", ARGV[1], "ver", ARGV[2])
+if tonumber(ARGV[3]) > 0 then
+    redis.call("pexpire", KEYS[1], ARGV[3])
+else
+    redis.call("persist", KEYS[1])
+end
+return 1
@@ -0,0 +1,180 @@
+package consistency
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrSchedulerClosed Scheduler 已经关闭了，任务没有被接受
+var ErrSchedulerClosed = errors.New("consistency: scheduler 已经关闭")
+
+// Scheduler 在后台按照时间顺序执行延迟任务，所有任务共用一个 goroutine
+type Scheduler struct {
+	mu    sync.Mutex
+	tasks taskHeap
+	// closed 之后 tasks 不会再被执行了，受 mu 保护
+	closed bool
+	// wake 有新的任务可能比当前等待的更早
+	wake chan struct{}
+
+	closeOnce sync.Once
+	close     chan struct{}
+	done      chan struct{}
+
+	timeout    time.Duration
+	errHandler func(err error)
+}
+
+type task struct {
+	at time.Time
+	fn func(ctx context.Context) error
+}
+
+type SchedulerOption func(s *Scheduler)
+
+func NewScheduler(opts ...SchedulerOption) *Scheduler {
+	s := &Scheduler{
+		wake:       make(chan struct{}, 1),
+		close:      make(chan struct{}),
+		done:       make(chan struct{}),
+		timeout:    time.Second,
+		errHandler: func(err error) {},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	go s.loop()
+	return s
+}
+
+// SchedulerWithErrorHandler 任务失败的时候调用，比如打日志或者告警
+func SchedulerWithErrorHandler(fn func(err error)) SchedulerOption {
+	return func(s *Scheduler) {
+		s.errHandler = fn
+	}
+}
+
+// SchedulerWithTimeout 每个任务的超时时间，默认一秒
+func SchedulerWithTimeout(timeout time.Duration) SchedulerOption {
+	return func(s *Scheduler) {
+		s.timeout = timeout
+	}
+}
+
+// Schedule 在 delay 之后执行 fn。
+// Close 之后调用的话 fn 不会被执行，返回 ErrSchedulerClosed
+func (s *Scheduler) Schedule(delay time.Duration, fn func(ctx context.Context) error) error {
+	t := task{at: time.Now().Add(delay), fn: fn}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrSchedulerClosed
+	}
+	heap.Push(&s.tasks, t)
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Close 马上执行所有还没有执行的任务，然后退出。
+// 对于延迟删除来说，提前删除也比不删除好
+func (s *Scheduler) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.close)
+	})
+	<-s.done
+	return nil
+}
+
+func (s *Scheduler) loop() {
+	defer close(s.done)
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		s.mu.Lock()
+		wait := time.Hour
+		if len(s.tasks) > 0 {
+			wait = time.Until(s.tasks[0].at)
+		}
+		s.mu.Unlock()
+
+		if wait > 0 {
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(wait)
+			select {
+			case <-timer.C:
+			case <-s.wake:
+				continue
+			case <-s.close:
+				s.runAll()
+				return
+			}
+		}
+		s.runDue()
+	}
+}
+
+func (s *Scheduler) runDue() {
+	now := time.Now()
+	for {
+		s.mu.Lock()
+		if len(s.tasks) == 0 || s.tasks[0].at.After(now) {
+			s.mu.Unlock()
+			return
+		}
+		t := heap.Pop(&s.tasks).(task)
+		s.mu.Unlock()
+		s.run(t)
+	}
+}
+
+func (s *Scheduler) runAll() {
+	s.mu.Lock()
+	tasks := s.tasks
+	s.tasks = nil
+	s.closed = true
+	s.mu.Unlock()
+	for _, t := range tasks {
+		s.run(t)
+	}
+}
+
+func (s *Scheduler) run(t task) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	if err := t.fn(ctx); err != nil {
+		s.errHandler(err)
+	}
+}
+
+type taskHeap []task
+
+func (h taskHeap) Len() int { return len(h) }
+
+func (h taskHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+
+func (h taskHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *taskHeap) Push(x any) {
+	*h = append(*h, x.(task))
+}
+
+func (h *taskHeap) Pop() any {
+	old := *h
+	n := len(old)
+	t := old[n-1]
+	old[n-1] = task{}
+	*h = old[:n-1]
+	return t
+}
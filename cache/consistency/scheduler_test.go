@@ -0,0 +1,60 @@
+package consistency
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduler(t *testing.T) {
+	var mu sync.Mutex
+	var got []int
+	record := func(i int) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			mu.Lock()
+			got = append(got, i)
+			mu.Unlock()
+			return nil
+		}
+	}
+	errCh := make(chan error, 1)
+	s := NewScheduler(SchedulerWithErrorHandler(func(err error) {
+		errCh <- err
+	}))
+
+	s.Schedule(time.Millisecond*30, record(3))
+	s.Schedule(time.Millisecond*10, record(1))
+	s.Schedule(time.Millisecond*20, record(2))
+	s.Schedule(time.Millisecond, func(ctx context.Context) error {
+		return errors.New("mock error")
+	})
+	// 关闭的时候还没到时间的也会执行
+	s.Schedule(time.Hour, record(4))
+
+	assert.EqualError(t, <-errCh, "mock error")
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(got) == 3
+	}, time.Second, time.Millisecond*5)
+	assert.NoError(t, s.Close())
+	assert.Equal(t, []int{1, 2, 3, 4}, got)
+}
+
+func TestScheduler_ScheduleAfterClose(t *testing.T) {
+	s := NewScheduler()
+	assert.NoError(t, s.Close())
+
+	// 关闭之后的任务不会被执行，调用方要知道任务没有被接受
+	var ran bool
+	err := s.Schedule(0, func(ctx context.Context) error {
+		ran = true
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrSchedulerClosed)
+	assert.False(t, ran)
+}
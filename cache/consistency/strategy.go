@@ -0,0 +1,57 @@
+package consistency
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// UpdateFunc 更新数据库，返回新的值和版本号。
+// 版本号可以是数据库里面的版本字段，也可以是更新时间戳，必须是非负数。
+// 只用到 error 的策略（比如 DoubleDelete）会忽略值和版本号
+type UpdateFunc func(ctx context.Context) (val any, version int64, err error)
+
+// Strategy 决定更新数据库之后怎么处理缓存
+type Strategy interface {
+	Update(ctx context.Context, key string, update UpdateFunc) error
+}
+
+// Router 按照 key 的前缀选择策略，比如 user: 用 DoubleDelete，order: 用 Versioned。
+// 多个前缀都匹配的时候用最长的那个，都不匹配的时候用默认策略
+type Router struct {
+	defaultStrategy Strategy
+	prefixes        []string
+	strategies      map[string]Strategy
+}
+
+func NewRouter(defaultStrategy Strategy) *Router {
+	return &Router{
+		defaultStrategy: defaultStrategy,
+		strategies:      make(map[string]Strategy),
+	}
+}
+
+// Register 不是并发安全的，需要在启动的时候注册好
+func (r *Router) Register(prefix string, s Strategy) {
+	if _, ok := r.strategies[prefix]; !ok {
+		r.prefixes = append(r.prefixes, prefix)
+		// 长的排在前面，第一个匹配上的就是最长的
+		sort.Slice(r.prefixes, func(i, j int) bool {
+			return len(r.prefixes[i]) > len(r.prefixes[j])
+		})
+	}
+	r.strategies[prefix] = s
+}
+
+func (r *Router) Update(ctx context.Context, key string, update UpdateFunc) error {
+	return r.strategy(key).Update(ctx, key, update)
+}
+
+func (r *Router) strategy(key string) Strategy {
+	for _, prefix := range r.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return r.strategies[prefix]
+		}
+	}
+	return r.defaultStrategy
+}
@@ -0,0 +1,36 @@
+package consistency
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type namedStrategy string
+
+func (n namedStrategy) Update(ctx context.Context, key string, update UpdateFunc) error {
+	return nil
+}
+
+func TestRouter_strategy(t *testing.T) {
+	r := NewRouter(namedStrategy("default"))
+	r.Register("user:", namedStrategy("user"))
+	r.Register("user:vip:", namedStrategy("vip"))
+	r.Register("order:", namedStrategy("order"))
+
+	testCases := []struct {
+		key  string
+		want Strategy
+	}{
+		{key: "user:1", want: namedStrategy("user")},
+		{key: "user:vip:1", want: namedStrategy("vip")},
+		{key: "order:1", want: namedStrategy("order")},
+		{key: "product:1", want: namedStrategy("default")},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.key, func(t *testing.T) {
+			assert.Equal(t, tc.want, r.strategy(tc.key))
+		})
+	}
+}
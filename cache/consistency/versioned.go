@@ -0,0 +1,77 @@
+package consistency
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	cache "github.com/luxpo/time-go2nd/cache/redis"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	_ Strategy = (*Versioned)(nil)
+
+	//go:embed lua/set_if_newer.lua
+	luaSetIfNewer string
+
+	errInvalidVersion = errors.New("consistency: 版本号不能是负数")
+)
+
+// Versioned 缓存里面同时保存值和版本号（一个 hash），
+// 只有版本号更新的时候才会覆盖，旧数据回填的时候会被拒绝。
+// 读请求回填缓存的时候也要用 SetIfNewer，带上从数据库里面读到的版本号
+type Versioned struct {
+	client     redis.Cmdable
+	expiration time.Duration
+}
+
+func NewVersioned(client redis.Cmdable, expiration time.Duration) *Versioned {
+	return &Versioned{
+		client:     client,
+		expiration: expiration,
+	}
+}
+
+func (v *Versioned) Update(ctx context.Context, key string, update UpdateFunc) error {
+	val, version, err := update(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = v.SetIfNewer(ctx, key, val, version)
+	return err
+}
+
+// SetIfNewer 缓存里面的版本号比 version 小，或者缓存不存在的时候才写入，返回是否写入了
+func (v *Versioned) SetIfNewer(ctx context.Context, key string, val any, version int64) (bool, error) {
+	if version < 0 {
+		return false, fmt.Errorf("%w, key: %s, version: %d", errInvalidVersion, key, version)
+	}
+	res, err := v.client.Eval(ctx, luaSetIfNewer, []string{key},
+		val, strconv.FormatInt(version, 10), v.expiration.Milliseconds()).Int64()
+	if err != nil {
+		return false, err
+	}
+	return res == 1, nil
+}
+
+// Get 返回值和版本号，不存在的时候返回 cache.ErrKeyNotFound
+func (v *Versioned) Get(ctx context.Context, key string) (string, int64, error) {
+	res, err := v.client.HMGet(ctx, key, "val", "ver").Result()
+	if err != nil {
+		return "", 0, err
+	}
+	val, ok1 := res[0].(string)
+	ver, ok2 := res[1].(string)
+	if !ok1 || !ok2 {
+		return "", 0, fmt.Errorf("%w, key: %s", cache.ErrKeyNotFound, key)
+	}
+	version, err := strconv.ParseInt(ver, 10, 64)
+	if err != nil {
+		return "", 0, err
+	}
+	return val, version, nil
+}
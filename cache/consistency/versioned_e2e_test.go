@@ -0,0 +1,40 @@
+//go:build e2e
+
+package consistency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersioned_e2e(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	defer rdb.Del(ctx, "versioned:e2e")
+	v := NewVersioned(rdb, time.Minute)
+
+	ok, err := v.SetIfNewer(ctx, "versioned:e2e", "v10", 10)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	// 旧数据回填被拒绝
+	ok, err = v.SetIfNewer(ctx, "versioned:e2e", "v9", 9)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	// 位数不一样的时候也要按照数值比较
+	ok, err = v.SetIfNewer(ctx, "versioned:e2e", "v100", 100)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	val, version, err := v.Get(ctx, "versioned:e2e")
+	require.NoError(t, err)
+	assert.Equal(t, "v100", val)
+	assert.Equal(t, int64(100), version)
+}
@@ -0,0 +1,91 @@
+package consistency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	cache "github.com/luxpo/time-go2nd/cache/redis"
+	"github.com/luxpo/time-go2nd/cache/redis/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersioned_SetIfNewer(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mock    func(ctrl *gomock.Controller) redis.Cmdable
+		version int64
+
+		wantOK  bool
+		wantErr error
+	}{
+		{
+			name: "newer",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				res := redis.NewCmd(context.Background())
+				res.SetVal(int64(1))
+				cmd.EXPECT().Eval(gomock.Any(), luaSetIfNewer, []string{"order:1"},
+					"v1", "1700000000000000000", int64(60000)).Return(res)
+				return cmd
+			},
+			version: 1700000000000000000,
+			wantOK:  true,
+		},
+		{
+			name: "older",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				res := redis.NewCmd(context.Background())
+				res.SetVal(int64(0))
+				cmd.EXPECT().Eval(gomock.Any(), luaSetIfNewer, []string{"order:1"},
+					"v1", "3", int64(60000)).Return(res)
+				return cmd
+			},
+			version: 3,
+		},
+		{
+			name: "negative version",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				return mocks.NewMockCmdable(ctrl)
+			},
+			version: -1,
+			wantErr: errInvalidVersion,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			v := NewVersioned(tc.mock(ctrl), time.Minute)
+			ok, err := v.SetIfNewer(context.Background(), "order:1", "v1", tc.version)
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.wantOK, ok)
+		})
+	}
+}
+
+func TestVersioned_Get(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := mocks.NewMockCmdable(ctrl)
+	found := redis.NewSliceCmd(context.Background())
+	found.SetVal([]any{"v1", "12"})
+	cmd.EXPECT().HMGet(gomock.Any(), "order:1", "val", "ver").Return(found)
+	missing := redis.NewSliceCmd(context.Background())
+	missing.SetVal([]any{nil, nil})
+	cmd.EXPECT().HMGet(gomock.Any(), "order:2", "val", "ver").Return(missing)
+
+	v := NewVersioned(cmd, time.Minute)
+	val, version, err := v.Get(context.Background(), "order:1")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", val)
+	assert.Equal(t, int64(12), version)
+
+	_, _, err = v.Get(context.Background(), "order:2")
+	assert.ErrorIs(t, err, cache.ErrKeyNotFound)
+}
@@ -0,0 +1,14 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrKeyNotFound 所有缓存实现在 key 不存在的时候都返回这个错误，
+// 这样装饰器可以用 errors.Is 区分 key 不存在和真正的错误
+var ErrKeyNotFound = errors.New("cache：key not found")
+
+func NewErrKeyNotFound(key string) error {
+	return fmt.Errorf("%w, key: %s", ErrKeyNotFound, key)
+}
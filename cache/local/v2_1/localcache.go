@@ -5,6 +5,8 @@ import (
 	"errors"
 	"sync"
 	"time"
+
+	"github.com/luxpo/time-go2nd/clock"
 )
 
 type LocalCache struct {
@@ -15,6 +17,8 @@ type LocalCache struct {
 	close     chan struct{}
 
 	onEvicted func(k string, v any)
+
+	clock clock.Clock
 }
 
 type LocalCacheOption func(cache *LocalCache)
@@ -28,6 +32,7 @@ func NewLocalCache(interval time.Duration, opts ...LocalCacheOption) *LocalCache
 	c := &LocalCache{
 		data:  make(map[string]*item),
 		close: make(chan struct{}),
+		clock: clock.New(),
 	}
 
 	for _, opt := range opts {
@@ -35,10 +40,10 @@ func NewLocalCache(interval time.Duration, opts ...LocalCacheOption) *LocalCache
 	}
 
 	go func() {
-		ticker := time.NewTicker(interval)
+		ticker := c.clock.NewTicker(interval)
 		for {
 			select {
-			case t := <-ticker.C:
+			case t := <-ticker.C():
 				c.mu.Lock()
 				i := 0
 				for k, v := range c.data {
@@ -66,10 +71,17 @@ func LocalCacheWithEvictedCallback(fn func(k string, v any)) LocalCacheOption {
 	}
 }
 
+// LocalCacheWithClock 一般只在测试里面使用
+func LocalCacheWithClock(clk clock.Clock) LocalCacheOption {
+	return func(cache *LocalCache) {
+		cache.clock = clk
+	}
+}
+
 func (c *LocalCache) Set(ctx context.Context, k string, v any, expiration time.Duration) error {
 	var dl time.Time
 	if expiration > 0 {
-		dl = time.Now().Add(expiration)
+		dl = c.clock.Now().Add(expiration)
 	}
 
 	c.mu.Lock()
@@ -93,7 +105,7 @@ func (c *LocalCache) Get(ctx context.Context, k string) (any, error) {
 		return nil, errors.New("key not found")
 	}
 
-	now := time.Now()
+	now := c.clock.Now()
 	// 定时轮询单独使用肯定是不行的，
 	// 因为一个 key 可能已经过期了，但是还没轮到它，
 	// 一般都是跟 Get 的时候检查过期时间配合使用。
@@ -2,9 +2,11 @@ package v2_1
 
 import (
 	"context"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/luxpo/time-go2nd/clock"
 	"github.com/stretchr/testify/require"
 )
 
@@ -23,3 +25,30 @@ func TestLocalCache_Loop(t *testing.T) {
 	require.False(t, ok)
 	require.Equal(t, 1, cnt)
 }
+
+func TestLocalCache_FakeClock(t *testing.T) {
+	clk := clock.NewFakeClock(time.Unix(0, 0))
+	var cnt atomic.Int32
+	c := NewLocalCache(time.Second, LocalCacheWithClock(clk), LocalCacheWithEvictedCallback(func(k string, v any) {
+		cnt.Add(1)
+	}))
+	defer c.Close()
+	err := c.Set(context.Background(), "k", 123, time.Second)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return clk.Waiters() == 1
+	}, time.Second, time.Millisecond)
+	clk.Advance(time.Second)
+	_, err = c.Get(context.Background(), "k")
+	require.NoError(t, err)
+
+	clk.Advance(time.Second)
+	require.Eventually(t, func() bool {
+		return cnt.Load() == 1
+	}, time.Second, time.Millisecond)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.data["k"]
+	require.False(t, ok)
+}
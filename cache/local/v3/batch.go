@@ -0,0 +1,167 @@
+package v3
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/luxpo/time-go2nd/cache/internal/errs"
+)
+
+// MGet 和 Get 一样在读锁下面读，只有发现过期的 key 的时候才加一次写锁删掉它们。
+// 返回的 map 里面只有存在的 key，
+// 不存在的 key 每个都对应一个 ErrKeyNotFound，用 errors.Join 合并起来返回
+func (c *LocalCache) MGet(ctx context.Context, keys []string) (map[string]any, error) {
+	now := c.clock.Now()
+	vals := make(map[string]any, len(keys))
+	var (
+		errList []error
+		expired []string
+	)
+	c.mu.RLock()
+	for _, k := range keys {
+		i, ok := c.data[k]
+		if !ok {
+			c.stats.RecordMiss()
+			errList = append(errList, errs.NewErrKeyNotFound(k))
+			continue
+		}
+		if i.deadlineBeforeNow(now) {
+			expired = append(expired, k)
+			continue
+		}
+		c.stats.RecordHit()
+		vals[k] = i.val
+	}
+	c.mu.RUnlock()
+	if len(expired) == 0 {
+		return vals, errors.Join(errList...)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, k := range expired {
+		// double check，期间可能被删掉或者重新写入了
+		i, ok := c.data[k]
+		if ok && i.deadlineBeforeNow(now) {
+			c.delete(k, EvictReasonExpired)
+			ok = false
+		}
+		if !ok {
+			c.stats.RecordMiss()
+			errList = append(errList, errs.NewErrKeyNotFound(k))
+			continue
+		}
+		c.stats.RecordHit()
+		vals[k] = i.val
+	}
+	return vals, errors.Join(errList...)
+}
+
+// MSet 只加一次锁，所有的 key 使用同样的过期时间
+func (c *LocalCache) MSet(ctx context.Context, vals map[string]any, expiration time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, v := range vals {
+		c.set(k, v, expiration)
+		c.stats.RecordSet()
+	}
+	return nil
+}
+
+// MDelete 只加一次锁，不存在的 key 会被忽略
+func (c *LocalCache) MDelete(ctx context.Context, keys []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, k := range keys {
+		c.delete(k, EvictReasonExplicit)
+		c.stats.RecordDelete()
+	}
+	return nil
+}
+
+// MGet 和 LocalCache.MGet 一样，同时更新淘汰策略
+func (c *MaxCntCache) MGet(ctx context.Context, keys []string) (map[string]any, error) {
+	vals, err := c.LocalCache.MGet(ctx, keys)
+	if len(vals) == 0 {
+		return vals, err
+	}
+	c.mu.Lock()
+	for k := range vals {
+		// 有可能在这期间已经被删除了
+		if _, ok := c.data[k]; ok {
+			c.policy.KeyAccessed(k)
+		}
+	}
+	c.mu.Unlock()
+	return vals, err
+}
+
+// MSet 只加一次锁，写入失败的 key 的错误会合并起来返回，其它的 key 依旧会写入
+func (c *MaxCntCache) MSet(ctx context.Context, vals map[string]any, expiration time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var errList []error
+	for k, v := range vals {
+		if err := c.setLocked(k, v, expiration); err != nil {
+			errList = append(errList, err)
+		}
+	}
+	return errors.Join(errList...)
+}
+
+// MGet 和 LocalCache.MGet 一样，同时更新淘汰策略
+func (c *MaxMemoryCache) MGet(ctx context.Context, keys []string) (map[string]any, error) {
+	vals, err := c.LocalCache.MGet(ctx, keys)
+	if len(vals) == 0 {
+		return vals, err
+	}
+	c.mu.Lock()
+	for k := range vals {
+		// 有可能在这期间已经被删除了
+		if _, ok := c.data[k]; ok {
+			c.policy.KeyAccessed(k)
+		}
+	}
+	c.mu.Unlock()
+	return vals, err
+}
+
+// MSet 只加一次锁，写入失败的 key 的错误会合并起来返回，其它的 key 依旧会写入
+func (c *MaxMemoryCache) MSet(ctx context.Context, vals map[string]any, expiration time.Duration) error {
+	sizes := make(map[string]int64, len(vals))
+	var errList []error
+	for k, v := range vals {
+		size, err := c.size(k, v)
+		if err != nil {
+			errList = append(errList, err)
+			continue
+		}
+		sizes[k] = size
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, size := range sizes {
+		if err := c.setLocked(k, vals[k], size, expiration); err != nil {
+			errList = append(errList, err)
+		}
+	}
+	return errors.Join(errList...)
+}
+
+// FlushPrefix 删除所有以 prefix 开头的 key，需要遍历所有的 key
+func (c *LocalCache) FlushPrefix(ctx context.Context, prefix string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var cnt int64
+	for k := range c.data {
+		if strings.HasPrefix(k, prefix) {
+			c.delete(k, EvictReasonExplicit)
+			c.stats.RecordDelete()
+			cnt++
+		}
+	}
+	return cnt, nil
+}
@@ -0,0 +1,104 @@
+package v3
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/luxpo/time-go2nd/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalCache_Batch(t *testing.T) {
+	clk := clock.NewFakeClock(time.Unix(0, 0))
+	c := NewLocalCache(time.Minute, LocalCacheWithClock(clk))
+	defer c.Close()
+	ctx := context.Background()
+
+	require.NoError(t, c.MSet(ctx, map[string]any{"k1": 1, "k2": 2}, time.Minute))
+	require.NoError(t, c.Set(ctx, "k3", 3, time.Second))
+	clk.Advance(time.Second * 2)
+
+	vals, err := c.MGet(ctx, []string{"k1", "k2", "k3", "k4"})
+	assert.Equal(t, map[string]any{"k1": 1, "k2": 2}, vals)
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	// 每个不存在的 key 都有一个错误
+	assert.ErrorContains(t, err, "key: k3")
+	assert.ErrorContains(t, err, "key: k4")
+	// 过期的 key 被顺便删掉了
+	_, ok := c.data["k3"]
+	assert.False(t, ok)
+
+	// 没有过期的 key 的时候只需要读锁
+	c.mu.RLock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		vals, err = c.MGet(ctx, []string{"k1", "k2"})
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("MGet 等待写锁")
+	}
+	c.mu.RUnlock()
+	require.NoError(t, err)
+	assert.Len(t, vals, 2)
+
+	require.NoError(t, c.MDelete(ctx, []string{"k1", "k4"}))
+	vals, err = c.MGet(ctx, []string{"k1", "k2"})
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	assert.Equal(t, map[string]any{"k2": 2}, vals)
+
+	s := c.Stats()
+	assert.Equal(t, uint64(5), s.Hits)
+	assert.Equal(t, uint64(3), s.Misses)
+}
+
+func TestMaxCntCache_MSet(t *testing.T) {
+	c := NewMaxCntCache(NewLocalCache(time.Minute), 2)
+	defer c.Close()
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, "k1", 1, time.Minute))
+	require.NoError(t, c.MSet(ctx, map[string]any{"k2": 2, "k3": 3}, time.Minute))
+	// k1 被淘汰了
+	vals, err := c.MGet(ctx, []string{"k1", "k2", "k3"})
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	assert.Equal(t, map[string]any{"k2": 2, "k3": 3}, vals)
+
+	require.NoError(t, c.MDelete(ctx, []string{"k2", "k3"}))
+	assert.Equal(t, int32(0), c.cnt)
+}
+
+func TestMaxMemoryCache_MSet(t *testing.T) {
+	c := NewMaxMemoryCache(NewLocalCache(time.Minute), 4)
+	defer c.Close()
+	ctx := context.Background()
+
+	err := c.MSet(ctx, map[string]any{"k1": "ab", "k2": 2, "k3": "abcde"}, time.Minute)
+	// 失败的 key 不影响其它的 key
+	assert.ErrorIs(t, err, errUnsizedValue)
+	assert.ErrorIs(t, err, errEntryTooLarge)
+	vals, err := c.MGet(ctx, []string{"k1", "k2", "k3"})
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	assert.Equal(t, map[string]any{"k1": "ab"}, vals)
+	assert.Equal(t, int64(2), c.used)
+}
+
+func TestLocalCache_FlushPrefix(t *testing.T) {
+	c := NewMaxCntCache(NewLocalCache(time.Minute), 10)
+	defer c.Close()
+	ctx := context.Background()
+	require.NoError(t, c.MSet(ctx, map[string]any{"a:k1": 1, "a:k2": 2, "b:k1": 3}, time.Minute))
+
+	cnt, err := c.FlushPrefix(ctx, "a:")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), cnt)
+	vals, err := c.MGet(ctx, []string{"a:k1", "a:k2", "b:k1"})
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	assert.Equal(t, map[string]any{"b:k1": 3}, vals)
+	// 装饰器的计数也更新了
+	assert.Equal(t, int32(1), c.cnt)
+}
@@ -0,0 +1,88 @@
+package v3
+
+import "sync"
+
+// EvictReason 表示一个 key 为什么被移除
+type EvictReason uint8
+
+const (
+	// EvictReasonExpired 过期
+	EvictReasonExpired EvictReason = iota + 1
+	// EvictReasonCapacity 容量不足被淘汰
+	EvictReasonCapacity
+	// EvictReasonExplicit 用户调用 Delete 或者 LoadAndDelete
+	EvictReasonExplicit
+	// EvictReasonReplaced 被 Set 覆盖，回调里面拿到的是旧的值
+	EvictReasonReplaced
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictReasonExpired:
+		return "expired"
+	case EvictReasonCapacity:
+		return "capacity"
+	case EvictReasonExplicit:
+		return "explicit"
+	case EvictReasonReplaced:
+		return "replaced"
+	default:
+		return "unknown"
+	}
+}
+
+// EvictionListener 在 key 被移除之后调用。
+// 同步模式下是在持有锁的时候调用的，不能在里面再操作缓存。
+type EvictionListener func(k string, v any, reason EvictReason)
+
+type evictEvent struct {
+	key    string
+	val    any
+	reason EvictReason
+}
+
+// evictDispatcher 异步投递移除事件。
+// 队列是无界的，这样写入的时候不会阻塞，也不会丢事件。
+// 有事件并且没有 goroutine 在投递的时候才启动一个，队列空了就退出，
+// 所以同一时间只有一个 goroutine 按顺序投递，也不需要关心缓存有没有关闭
+type evictDispatcher struct {
+	mu    sync.Mutex
+	queue []evictEvent
+	// draining 已经有 goroutine 在投递了
+	draining bool
+
+	listener EvictionListener
+}
+
+func newEvictDispatcher(listener EvictionListener) *evictDispatcher {
+	return &evictDispatcher{
+		listener: listener,
+	}
+}
+
+func (d *evictDispatcher) dispatch(e evictEvent) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.queue = append(d.queue, e)
+	if !d.draining {
+		d.draining = true
+		go d.drain()
+	}
+}
+
+func (d *evictDispatcher) drain() {
+	for {
+		d.mu.Lock()
+		events := d.queue
+		d.queue = nil
+		if len(events) == 0 {
+			d.draining = false
+			d.mu.Unlock()
+			return
+		}
+		d.mu.Unlock()
+		for _, e := range events {
+			d.listener(e.key, e.val, e.reason)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package v3
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/luxpo/time-go2nd/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalCache_EvictionListener(t *testing.T) {
+	type event struct {
+		key    string
+		val    any
+		reason EvictReason
+	}
+	var events []event
+	clk := clock.NewFakeClock(time.Unix(0, 0))
+	lc := NewLocalCache(time.Minute, LocalCacheWithClock(clk),
+		LocalCacheWithEvictionListener(func(k string, v any, reason EvictReason) {
+			events = append(events, event{key: k, val: v, reason: reason})
+		}))
+	defer lc.Close()
+	c := NewMaxCntCache(lc, 2)
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, "k1", 1, time.Minute))
+	require.NoError(t, c.Set(ctx, "k1", 2, time.Minute))
+	require.NoError(t, c.Set(ctx, "k2", 1, time.Minute))
+	require.NoError(t, c.Set(ctx, "k3", 1, time.Minute))
+	require.NoError(t, c.Delete(ctx, "k2"))
+	_, err := c.LoadAndDelete(ctx, "k3")
+	require.NoError(t, err)
+	require.NoError(t, c.Set(ctx, "k4", 1, time.Millisecond))
+	clk.Advance(time.Millisecond * 5)
+	_, err = c.Get(ctx, "k4")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	// 删除不存在的 key 不会通知
+	require.NoError(t, c.Delete(ctx, "k5"))
+
+	assert.Equal(t, []event{
+		{key: "k1", val: 1, reason: EvictReasonReplaced},
+		{key: "k1", val: 2, reason: EvictReasonCapacity},
+		{key: "k2", val: 1, reason: EvictReasonExplicit},
+		{key: "k3", val: 1, reason: EvictReasonExplicit},
+		{key: "k4", val: 1, reason: EvictReasonExpired},
+	}, events)
+	assert.Equal(t, int32(0), c.cnt)
+}
+
+func TestLocalCache_AsyncEviction(t *testing.T) {
+	var mu sync.Mutex
+	var keys []string
+	c := NewLocalCache(time.Minute, LocalCacheWithAsyncEviction(),
+		LocalCacheWithEvictionListener(func(k string, v any, reason EvictReason) {
+			// 在锁外面执行，可以回头操作缓存
+			mu.Lock()
+			keys = append(keys, k)
+			mu.Unlock()
+		}))
+	ctx := context.Background()
+	for _, k := range []string{"k1", "k2", "k3"} {
+		require.NoError(t, c.Set(ctx, k, 1, time.Minute))
+		require.NoError(t, c.Delete(ctx, k))
+	}
+	require.NoError(t, c.Close())
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(keys) == 3
+	}, time.Second, time.Millisecond*10)
+	mu.Lock()
+	assert.Equal(t, []string{"k1", "k2", "k3"}, keys)
+	mu.Unlock()
+
+	c.dispatcher.mu.Lock()
+	defer c.dispatcher.mu.Unlock()
+	assert.Empty(t, c.dispatcher.queue)
+}
+
+func TestLocalCache_AsyncEvictionAfterClose(t *testing.T) {
+	var c *LocalCache
+	got := make(chan any, 1)
+	c = NewLocalCache(time.Minute, LocalCacheWithAsyncEviction(),
+		LocalCacheWithEvictionListener(func(k string, v any, reason EvictReason) {
+			// 关闭之后也不能在持有锁的时候调用，不然这里会死锁
+			val, _ := c.Get(context.Background(), "k2")
+			got <- val
+		}))
+	ctx := context.Background()
+	require.NoError(t, c.Close())
+	require.NoError(t, c.Set(ctx, "k1", 1, time.Minute))
+	require.NoError(t, c.Set(ctx, "k2", 2, time.Minute))
+	require.NoError(t, c.Delete(ctx, "k1"))
+	select {
+	case val := <-got:
+		assert.Equal(t, 2, val)
+	case <-time.After(time.Second):
+		t.Fatal("关闭之后的移除事件没有投递")
+	}
+}
+
+func TestLocalCache_NoListener(t *testing.T) {
+	c := NewLocalCache(time.Minute)
+	defer c.Close()
+	ctx := context.Background()
+	require.NoError(t, c.Set(ctx, "k1", 1, time.Minute))
+	require.NoError(t, c.Set(ctx, "k1", 2, time.Minute))
+	_, err := c.LoadAndDelete(ctx, "k1")
+	require.NoError(t, err)
+}
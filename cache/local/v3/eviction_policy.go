@@ -0,0 +1,240 @@
+package v3
+
+import (
+	"container/heap"
+	"container/list"
+	"math/rand"
+)
+
+// EvictionPolicy 决定缓存满了之后淘汰哪个 key。
+// 实现不需要考虑并发安全，调用方会在持有写锁的情况下调用。
+type EvictionPolicy interface {
+	// KeyAccessed 在 key 被写入或者被读取的时候调用
+	KeyAccessed(key string)
+	// Evict 挑选一个 key 淘汰，并且将它从策略中移除。
+	// 没有可以淘汰的 key 的时候返回 false
+	Evict() (string, bool)
+	// Remove 在 key 被删除或者过期的时候调用
+	Remove(key string)
+}
+
+// readIgnoringPolicy 读取不会影响淘汰顺序的策略实现这个接口，
+// 这样 Get 的时候就不需要为了 KeyAccessed 拿写锁
+type readIgnoringPolicy interface {
+	ignoreReads()
+}
+
+var (
+	_ readIgnoringPolicy = (*FIFOPolicy)(nil)
+	_ readIgnoringPolicy = (*RandomPolicy)(nil)
+)
+
+var (
+	_ EvictionPolicy = (*LRUPolicy)(nil)
+	_ EvictionPolicy = (*LFUPolicy)(nil)
+	_ EvictionPolicy = (*FIFOPolicy)(nil)
+	_ EvictionPolicy = (*RandomPolicy)(nil)
+)
+
+// LRUPolicy 淘汰最久没有被访问的 key
+type LRUPolicy struct {
+	list  *list.List
+	nodes map[string]*list.Element
+}
+
+func NewLRUPolicy() *LRUPolicy {
+	return &LRUPolicy{
+		list:  list.New(),
+		nodes: make(map[string]*list.Element),
+	}
+}
+
+func (p *LRUPolicy) KeyAccessed(key string) {
+	if e, ok := p.nodes[key]; ok {
+		p.list.MoveToFront(e)
+		return
+	}
+	p.nodes[key] = p.list.PushFront(key)
+}
+
+func (p *LRUPolicy) Evict() (string, bool) {
+	e := p.list.Back()
+	if e == nil {
+		return "", false
+	}
+	key := p.list.Remove(e).(string)
+	delete(p.nodes, key)
+	return key, true
+}
+
+func (p *LRUPolicy) Remove(key string) {
+	if e, ok := p.nodes[key]; ok {
+		p.list.Remove(e)
+		delete(p.nodes, key)
+	}
+}
+
+// FIFOPolicy 按照写入的顺序淘汰，读取不会影响顺序
+type FIFOPolicy struct {
+	list  *list.List
+	nodes map[string]*list.Element
+}
+
+func NewFIFOPolicy() *FIFOPolicy {
+	return &FIFOPolicy{
+		list:  list.New(),
+		nodes: make(map[string]*list.Element),
+	}
+}
+
+func (p *FIFOPolicy) KeyAccessed(key string) {
+	if _, ok := p.nodes[key]; ok {
+		return
+	}
+	p.nodes[key] = p.list.PushBack(key)
+}
+
+func (p *FIFOPolicy) ignoreReads() {}
+
+func (p *FIFOPolicy) Evict() (string, bool) {
+	e := p.list.Front()
+	if e == nil {
+		return "", false
+	}
+	key := p.list.Remove(e).(string)
+	delete(p.nodes, key)
+	return key, true
+}
+
+func (p *FIFOPolicy) Remove(key string) {
+	if e, ok := p.nodes[key]; ok {
+		p.list.Remove(e)
+		delete(p.nodes, key)
+	}
+}
+
+// LFUPolicy 淘汰访问次数最少的 key，次数相同的时候淘汰最久没有被访问的
+type LFUPolicy struct {
+	h     lfuHeap
+	nodes map[string]*lfuEntry
+	// 单调递增，用来在访问次数相同的时候区分先后
+	seq uint64
+}
+
+type lfuEntry struct {
+	key   string
+	freq  uint64
+	seq   uint64
+	index int
+}
+
+func NewLFUPolicy() *LFUPolicy {
+	return &LFUPolicy{
+		nodes: make(map[string]*lfuEntry),
+	}
+}
+
+func (p *LFUPolicy) KeyAccessed(key string) {
+	p.seq++
+	if e, ok := p.nodes[key]; ok {
+		e.freq++
+		e.seq = p.seq
+		heap.Fix(&p.h, e.index)
+		return
+	}
+	e := &lfuEntry{key: key, freq: 1, seq: p.seq}
+	p.nodes[key] = e
+	heap.Push(&p.h, e)
+}
+
+func (p *LFUPolicy) Evict() (string, bool) {
+	if p.h.Len() == 0 {
+		return "", false
+	}
+	e := heap.Pop(&p.h).(*lfuEntry)
+	delete(p.nodes, e.key)
+	return e.key, true
+}
+
+func (p *LFUPolicy) Remove(key string) {
+	if e, ok := p.nodes[key]; ok {
+		heap.Remove(&p.h, e.index)
+		delete(p.nodes, key)
+	}
+}
+
+type lfuHeap []*lfuEntry
+
+func (h lfuHeap) Len() int { return len(h) }
+
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].freq == h[j].freq {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].freq < h[j].freq
+}
+
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap) Push(x any) {
+	e := x.(*lfuEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *lfuHeap) Pop() any {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return e
+}
+
+// RandomPolicy 随机淘汰一个 key
+type RandomPolicy struct {
+	keys  []string
+	index map[string]int
+}
+
+func NewRandomPolicy() *RandomPolicy {
+	return &RandomPolicy{
+		index: make(map[string]int),
+	}
+}
+
+func (p *RandomPolicy) KeyAccessed(key string) {
+	if _, ok := p.index[key]; ok {
+		return
+	}
+	p.index[key] = len(p.keys)
+	p.keys = append(p.keys, key)
+}
+
+func (p *RandomPolicy) ignoreReads() {}
+
+func (p *RandomPolicy) Evict() (string, bool) {
+	if len(p.keys) == 0 {
+		return "", false
+	}
+	key := p.keys[rand.Intn(len(p.keys))]
+	p.Remove(key)
+	return key, true
+}
+
+func (p *RandomPolicy) Remove(key string) {
+	idx, ok := p.index[key]
+	if !ok {
+		return
+	}
+	// 和最后一个交换，这样删除是 O(1) 的
+	last := len(p.keys) - 1
+	p.keys[idx] = p.keys[last]
+	p.index[p.keys[idx]] = idx
+	p.keys = p.keys[:last]
+	delete(p.index, key)
+}
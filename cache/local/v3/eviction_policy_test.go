@@ -0,0 +1,94 @@
+package v3
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvictionPolicy_Evict(t *testing.T) {
+	testCases := []struct {
+		name   string
+		policy EvictionPolicy
+		access []string
+		remove []string
+
+		wantKeys []string
+	}{
+		{
+			name:     "lru",
+			policy:   NewLRUPolicy(),
+			access:   []string{"k1", "k2", "k3", "k1"},
+			wantKeys: []string{"k2", "k3", "k1"},
+		},
+		{
+			name:     "lru remove",
+			policy:   NewLRUPolicy(),
+			access:   []string{"k1", "k2", "k3"},
+			remove:   []string{"k2", "k4"},
+			wantKeys: []string{"k1", "k3"},
+		},
+		{
+			name:     "fifo",
+			policy:   NewFIFOPolicy(),
+			access:   []string{"k1", "k2", "k3", "k1"},
+			wantKeys: []string{"k1", "k2", "k3"},
+		},
+		{
+			name:     "lfu",
+			policy:   NewLFUPolicy(),
+			access:   []string{"k1", "k1", "k2", "k3", "k3", "k3"},
+			wantKeys: []string{"k2", "k1", "k3"},
+		},
+		{
+			name:     "lfu same frequency",
+			policy:   NewLFUPolicy(),
+			access:   []string{"k1", "k2", "k2", "k1"},
+			wantKeys: []string{"k2", "k1"},
+		},
+		{
+			name:     "lfu remove",
+			policy:   NewLFUPolicy(),
+			access:   []string{"k1", "k2", "k2", "k3"},
+			remove:   []string{"k1"},
+			wantKeys: []string{"k3", "k2"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, k := range tc.access {
+				tc.policy.KeyAccessed(k)
+			}
+			for _, k := range tc.remove {
+				tc.policy.Remove(k)
+			}
+			var keys []string
+			for {
+				k, ok := tc.policy.Evict()
+				if !ok {
+					break
+				}
+				keys = append(keys, k)
+			}
+			assert.Equal(t, tc.wantKeys, keys)
+		})
+	}
+}
+
+func TestRandomPolicy_Evict(t *testing.T) {
+	p := NewRandomPolicy()
+	for _, k := range []string{"k1", "k2", "k3", "k2"} {
+		p.KeyAccessed(k)
+	}
+	p.Remove("k3")
+	var keys []string
+	for {
+		k, ok := p.Evict()
+		if !ok {
+			break
+		}
+		keys = append(keys, k)
+	}
+	assert.ElementsMatch(t, []string{"k1", "k2"}, keys)
+}
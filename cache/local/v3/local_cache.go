@@ -2,24 +2,46 @@ package v3
 
 import (
 	"context"
-	"errors"
-	"fmt"
 	"sync"
 	"time"
+
+	"github.com/luxpo/time-go2nd/cache/internal/errs"
+	"github.com/luxpo/time-go2nd/cache/stats"
+	"github.com/luxpo/time-go2nd/clock"
 )
 
 var (
-	errKeyNotFound = errors.New("cache：key not found")
+	// ErrKeyNotFound 和 redis 缓存返回的是同一个错误
+	ErrKeyNotFound = errs.ErrKeyNotFound
 )
 
 type LocalCache struct {
 	mu   sync.RWMutex
 	data map[string]*item
+	// wheel 驱动 key 的过期，受 mu 保护
+	wheel *timingWheel
 
 	closeOnce sync.Once
 	close     chan struct{}
 
-	onEvicted func(k string, v any)
+	onEvicted EvictionListener
+	// asyncEvicted 为 true 的时候，onEvicted 会在单独的 goroutine 里面调用
+	asyncEvicted bool
+	dispatcher   *evictDispatcher
+	// removed 在 key 被移除的时候同步调用，给 MaxCntCache 之类的装饰器维护自己的状态。
+	// 覆盖不算移除。
+	removed func(k string)
+
+	codec            ValueCodec
+	snapshotPath     string
+	snapshotInterval time.Duration
+	// snapshotDone 在定时快照的 goroutine 退出之后关闭，没有启动的时候是 nil
+	snapshotDone chan struct{}
+
+	stats stats.Recorder
+	clock clock.Clock
+	// tickHook 在清理 goroutine 处理完一次 tick 之后调用，只在测试里面使用
+	tickHook func()
 }
 
 type LocalCacheOption func(cache *LocalCache)
@@ -30,32 +52,57 @@ type item struct {
 }
 
 func NewLocalCache(interval time.Duration, opts ...LocalCacheOption) *LocalCache {
+	return newLocalCache(interval, 0, opts...)
+}
+
+// newLocalCache delay 是清理 goroutine 第一次开始计时前的等待时间
+func newLocalCache(interval, delay time.Duration, opts ...LocalCacheOption) *LocalCache {
 	c := &LocalCache{
 		data:  make(map[string]*item),
 		close: make(chan struct{}),
+		codec: GobCodec{},
+		clock: clock.New(),
 	}
 
 	for _, opt := range opts {
 		opt(c)
 	}
+	c.wheel = newTimingWheel(interval, c.clock.Now())
+
+	if c.snapshotPath != "" {
+		_ = c.restoreFromFile()
+		if c.snapshotInterval > 0 {
+			c.snapshotDone = make(chan struct{})
+			go c.snapshotLoop()
+		}
+	}
+
+	if c.asyncEvicted && c.onEvicted != nil {
+		c.dispatcher = newEvictDispatcher(c.onEvicted)
+	}
 
 	go func() {
-		ticker := time.NewTicker(interval)
+		if delay > 0 {
+			select {
+			case <-c.clock.After(delay):
+			case <-c.close:
+				return
+			}
+		}
+		ticker := c.clock.NewTicker(interval)
+		defer ticker.Stop()
 		for {
 			select {
-			case t := <-ticker.C:
+			case t := <-ticker.C():
+				// 只处理时间轮里面到期的 key，持有锁的时间和过期 key 的数量成正比
 				c.mu.Lock()
-				i := 0
-				for k, v := range c.data {
-					if i > 10000 {
-						break
-					}
-					if v.deadlineBeforeNow(t) {
-						c.delete(k)
-					}
-					i++
+				for _, k := range c.wheel.advance(t) {
+					c.delete(k, EvictReasonExpired)
 				}
 				c.mu.Unlock()
+				if c.tickHook != nil {
+					c.tickHook()
+				}
 			case <-c.close:
 				return
 			}
@@ -65,26 +112,70 @@ func NewLocalCache(interval time.Duration, opts ...LocalCacheOption) *LocalCache
 	return c
 }
 
+// localCacheWithTickHook 测试里面用来等清理 goroutine 处理完到期的 key
+func localCacheWithTickHook(hook func()) LocalCacheOption {
+	return func(cache *LocalCache) {
+		cache.tickHook = hook
+	}
+}
+
+// LocalCacheWithClock 一般只在测试里面使用
+func LocalCacheWithClock(clk clock.Clock) LocalCacheOption {
+	return func(cache *LocalCache) {
+		cache.clock = clk
+	}
+}
+
+// LocalCacheWithEvictedCallback 不关心移除原因的时候使用
 func LocalCacheWithEvictedCallback(fn func(k string, v any)) LocalCacheOption {
 	return func(cache *LocalCache) {
-		cache.onEvicted = fn
+		cache.onEvicted = func(k string, v any, reason EvictReason) {
+			fn(k, v)
+		}
+	}
+}
+
+// LocalCacheWithEvictionListener 过期、淘汰、删除、覆盖都会通知 listener
+func LocalCacheWithEvictionListener(listener EvictionListener) LocalCacheOption {
+	return func(cache *LocalCache) {
+		cache.onEvicted = listener
+	}
+}
+
+// LocalCacheWithAsyncEviction 在单独的 goroutine 里面通知，
+// 这样慢的回调不会在持有锁的时候执行。Close 之后产生的通知也会被投递。
+func LocalCacheWithAsyncEviction() LocalCacheOption {
+	return func(cache *LocalCache) {
+		cache.asyncEvicted = true
 	}
 }
 
 func (c *LocalCache) Set(ctx context.Context, k string, v any, expiration time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(k, v, expiration)
+	c.stats.RecordSet()
+	return nil
+}
+
+// set 调用方需要持有写锁
+func (c *LocalCache) set(k string, v any, expiration time.Duration) {
 	var dl time.Time
 	if expiration > 0 {
-		dl = time.Now().Add(expiration)
+		dl = c.clock.Now().Add(expiration)
+	}
+	if old, ok := c.data[k]; ok {
+		c.notify(k, old.val, EvictReasonReplaced)
 	}
-
-	c.mu.Lock()
 	c.data[k] = &item{
 		val:      v,
 		deadline: dl,
 	}
-	c.mu.Unlock()
-
-	return nil
+	if expiration > 0 {
+		c.wheel.add(k, dl)
+	} else {
+		c.wheel.remove(k)
+	}
 }
 
 // Get 的时候，粗暴的做法是直接加写锁，但是也可以考虑用 double-check 写法。
@@ -95,10 +186,11 @@ func (c *LocalCache) Get(ctx context.Context, k string) (any, error) {
 	i, ok := c.data[k]
 	c.mu.RUnlock()
 	if !ok {
-		return nil, fmt.Errorf("%w, key: %s", errKeyNotFound, k)
+		c.stats.RecordMiss()
+		return nil, errs.NewErrKeyNotFound(k)
 	}
 
-	now := time.Now()
+	now := c.clock.Now()
 	// 定时轮询单独使用肯定是不行的，
 	// 因为一个 key 可能已经过期了，但是还没轮到它，
 	// 一般都是跟 Get 的时候检查过期时间配合使用。
@@ -108,21 +200,28 @@ func (c *LocalCache) Get(ctx context.Context, k string) (any, error) {
 		// double check
 		i, ok = c.data[k]
 		if !ok {
-			return nil, fmt.Errorf("%w, key: %s", errKeyNotFound, k)
+			c.stats.RecordMiss()
+			return nil, errs.NewErrKeyNotFound(k)
 		}
 		if i.deadlineBeforeNow(now) {
-			c.delete(k)
-			return nil, fmt.Errorf("%w, key: %s", errKeyNotFound, k)
+			c.delete(k, EvictReasonExpired)
+			c.stats.RecordMiss()
+			return nil, errs.NewErrKeyNotFound(k)
 		}
 	}
 
+	c.stats.RecordHit()
 	return i.val, nil
 }
 
 func (c *LocalCache) Delete(ctx context.Context, key string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	delete(c.data, key)
+	// 只统计真的删掉了数据的
+	if _, ok := c.data[key]; ok {
+		c.delete(key, EvictReasonExplicit)
+		c.stats.RecordDelete()
+	}
 	return nil
 }
 
@@ -130,13 +229,34 @@ func (i *item) deadlineBeforeNow(t time.Time) bool {
 	return !i.deadline.IsZero() && i.deadline.Before(t)
 }
 
-func (c *LocalCache) delete(k string) {
+// delete 调用方需要持有写锁
+func (c *LocalCache) delete(k string, reason EvictReason) {
 	item, ok := c.data[k]
 	if !ok {
 		return
 	}
 	delete(c.data, k)
-	c.onEvicted(k, item.val)
+	c.wheel.remove(k)
+	if c.removed != nil {
+		c.removed(k)
+	}
+	c.notify(k, item.val, reason)
+}
+
+func (c *LocalCache) notify(k string, v any, reason EvictReason) {
+	if reason == EvictReasonExpired {
+		c.stats.RecordExpiration()
+	} else {
+		c.stats.RecordEviction(reason.String())
+	}
+	if c.onEvicted == nil {
+		return
+	}
+	if c.dispatcher != nil {
+		c.dispatcher.dispatch(evictEvent{key: k, val: v, reason: reason})
+		return
+	}
+	c.onEvicted(k, v, reason)
 }
 
 func (c *LocalCache) Close() error {
@@ -149,11 +269,21 @@ func (c *LocalCache) Close() error {
 	// }
 
 	// 方法二
+	// 用 close 而不是发送，因为清理 goroutine 和定时快照的 goroutine 都要退出
+	var err error
 	c.closeOnce.Do(func() {
-		c.close <- struct{}{}
+		close(c.close)
+		if c.snapshotPath == "" {
+			return
+		}
+		// 等正在写的定时快照结束，不然它可能会用旧的快照覆盖最后这一次
+		if c.snapshotDone != nil {
+			<-c.snapshotDone
+		}
+		err = c.snapshotToFile()
 	})
 
-	return nil
+	return err
 }
 
 func (c *LocalCache) LoadAndDelete(ctx context.Context, key string) (any, error) {
@@ -161,8 +291,18 @@ func (c *LocalCache) LoadAndDelete(ctx context.Context, key string) (any, error)
 	defer c.mu.Unlock()
 	v, ok := c.data[key]
 	if !ok {
-		return nil, errKeyNotFound
+		return nil, errs.NewErrKeyNotFound(key)
 	}
-	c.delete(key)
+	c.delete(key, EvictReasonExplicit)
+	c.stats.RecordDelete()
 	return v.val, nil
 }
+
+// Stats 返回统计数据，MaxCntCache 之类的装饰器也可以直接使用
+func (c *LocalCache) Stats() stats.Stats {
+	s := c.stats.Stats()
+	c.mu.RLock()
+	s.Size = int64(len(c.data))
+	c.mu.RUnlock()
+	return s
+}
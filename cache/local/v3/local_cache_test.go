@@ -0,0 +1,56 @@
+package v3
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/luxpo/time-go2nd/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalCache_FakeClock(t *testing.T) {
+	clk := clock.NewFakeClock(time.Unix(0, 0))
+	var expired atomic.Int32
+	c := NewLocalCache(time.Second, LocalCacheWithClock(clk),
+		LocalCacheWithEvictionListener(func(k string, v any, reason EvictReason) {
+			if reason == EvictReasonExpired {
+				expired.Add(1)
+			}
+		}))
+	defer c.Close()
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, "k1", 1, time.Second*2))
+	require.NoError(t, c.Set(ctx, "k2", 2, time.Second*10))
+	require.NoError(t, c.Set(ctx, "k3", 3, 0))
+
+	clk.Advance(time.Second)
+	val, err := c.Get(ctx, "k1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, val)
+
+	// Get 的时候发现过期
+	clk.Advance(time.Second + time.Nanosecond)
+	_, err = c.Get(ctx, "k1")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+
+	// 等清理 goroutine 创建好 ticker
+	require.Eventually(t, func() bool {
+		return clk.Waiters() == 1
+	}, time.Second, time.Millisecond)
+	// 没有人读 k2，由清理 goroutine 删除
+	clk.Advance(time.Second * 8)
+	assert.Eventually(t, func() bool {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+		_, ok := c.data["k2"]
+		return !ok
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, int32(2), expired.Load())
+
+	_, err = c.Get(ctx, "k3")
+	assert.NoError(t, err)
+}
@@ -3,6 +3,8 @@ package v3
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"sync/atomic"
 	"time"
 )
@@ -13,39 +15,121 @@ var (
 
 type MaxCntCache struct {
 	*LocalCache
+	// cnt 受 LocalCache.mu 保护
 	cnt    int32
 	maxCnt atomic.Int32
+	policy EvictionPolicy
+	// recordReads 策略需要知道读操作，Get 的时候要拿写锁
+	recordReads bool
 }
 
-func NewMaxCntCache(c *LocalCache, maxCnt int32) *MaxCntCache {
+type MaxCntCacheOption func(c *MaxCntCache)
+
+// NewMaxCntCache 默认使用 LRU 淘汰策略
+func NewMaxCntCache(c *LocalCache, maxCnt int32, opts ...MaxCntCacheOption) *MaxCntCache {
 	newCache := &MaxCntCache{
 		LocalCache: c,
+		policy:     NewLRUPolicy(),
 	}
 	newCache.maxCnt.Store(maxCnt)
 
-	evictFunc := c.onEvicted
-	newCache.onEvicted = func(k string, v any) {
-		newCache.maxCnt.Add(-1)
-		if evictFunc != nil {
-			evictFunc(k, v)
+	for _, opt := range opts {
+		opt(newCache)
+	}
+	_, ignore := newCache.policy.(readIgnoringPolicy)
+	newCache.recordReads = !ignore
+
+	removed := c.removed
+	newCache.removed = func(k string) {
+		newCache.cnt--
+		newCache.policy.Remove(k)
+		if removed != nil {
+			removed(k)
 		}
 	}
 
+	newCache.adopt()
 	return newCache
 }
 
+// adopt 把 LocalCache 里面已有的数据纳入计数，
+// 例如从快照文件恢复的数据，超过容量的部分按照淘汰策略淘汰
+func (c *MaxCntCache) adopt() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k := range c.data {
+		c.cnt++
+		c.policy.KeyAccessed(k)
+	}
+	for c.cnt > c.maxCnt.Load() {
+		victim, ok := c.policy.Evict()
+		if !ok {
+			return
+		}
+		c.delete(victim, EvictReasonCapacity)
+	}
+}
+
+// Restore 从 r 里面恢复数据，和 Set 一样受到容量的限制
+func (c *MaxCntCache) Restore(r io.Reader) error {
+	entries, err := c.readSnapshot(r)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var errs []error
+	for _, e := range entries {
+		if err = c.setLocked(e.key, e.val, e.expiration); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func MaxCntCacheWithEvictionPolicy(p EvictionPolicy) MaxCntCacheOption {
+	return func(c *MaxCntCache) {
+		c.policy = p
+	}
+}
+
 func (c *MaxCntCache) Set(ctx context.Context, k string, v any, expiration time.Duration) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	return c.setLocked(k, v, expiration)
+}
 
+// setLocked 调用方需要持有写锁
+func (c *MaxCntCache) setLocked(k string, v any, expiration time.Duration) error {
 	_, ok := c.data[k]
 	if !ok {
-		if c.cnt+1 > c.maxCnt.Load() {
-			// 淘汰策略
-			return errOverCapacity
+		for c.cnt+1 > c.maxCnt.Load() {
+			victim, ok := c.policy.Evict()
+			if !ok {
+				return fmt.Errorf("%w, key: %s", errOverCapacity, k)
+			}
+			// delete 会触发 removed，在里面维护 cnt
+			c.delete(victim, EvictReasonCapacity)
 		}
 		c.cnt++
 	}
 
-	return c.Set(ctx, k, v, expiration)
+	c.set(k, v, expiration)
+	c.stats.RecordSet()
+	c.policy.KeyAccessed(k)
+	return nil
+}
+
+func (c *MaxCntCache) Get(ctx context.Context, k string) (any, error) {
+	val, err := c.LocalCache.Get(ctx, k)
+	if err != nil || !c.recordReads {
+		return val, err
+	}
+	c.mu.Lock()
+	// 有可能在这期间已经被删除了
+	if _, ok := c.data[k]; ok {
+		c.policy.KeyAccessed(k)
+	}
+	c.mu.Unlock()
+	return val, nil
 }
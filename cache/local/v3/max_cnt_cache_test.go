@@ -0,0 +1,116 @@
+package v3
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxCntCache_Set(t *testing.T) {
+	testCases := []struct {
+		name   string
+		policy EvictionPolicy
+		// 缓存满了之后才执行的读操作
+		get []string
+
+		wantEvicted []string
+		wantKeys    []string
+	}{
+		{
+			name:        "lru",
+			policy:      NewLRUPolicy(),
+			get:         []string{"k1"},
+			wantEvicted: []string{"k2"},
+			wantKeys:    []string{"k1", "k3", "k4"},
+		},
+		{
+			name:        "fifo",
+			policy:      NewFIFOPolicy(),
+			get:         []string{"k1"},
+			wantEvicted: []string{"k1"},
+			wantKeys:    []string{"k2", "k3", "k4"},
+		},
+		{
+			name:        "lfu",
+			policy:      NewLFUPolicy(),
+			get:         []string{"k1", "k2", "k2", "k3"},
+			wantEvicted: []string{"k1"},
+			wantKeys:    []string{"k2", "k3", "k4"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var evicted []string
+			lc := NewLocalCache(time.Minute, LocalCacheWithEvictedCallback(func(k string, v any) {
+				evicted = append(evicted, k)
+			}))
+			defer lc.Close()
+			c := NewMaxCntCache(lc, 3, MaxCntCacheWithEvictionPolicy(tc.policy))
+			ctx := context.Background()
+			for _, k := range []string{"k1", "k2", "k3"} {
+				require.NoError(t, c.Set(ctx, k, k, time.Minute))
+			}
+			for _, k := range tc.get {
+				_, err := c.Get(ctx, k)
+				require.NoError(t, err)
+			}
+
+			require.NoError(t, c.Set(ctx, "k4", "k4", time.Minute))
+			assert.Equal(t, tc.wantEvicted, evicted)
+			for _, k := range tc.wantKeys {
+				_, err := c.Get(ctx, k)
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, int32(3), c.cnt)
+		})
+	}
+}
+
+func TestMaxCntCache_Delete(t *testing.T) {
+	lc := NewLocalCache(time.Minute)
+	defer lc.Close()
+	c := NewMaxCntCache(lc, 2)
+	ctx := context.Background()
+	require.NoError(t, c.Set(ctx, "k1", 1, time.Minute))
+	require.NoError(t, c.Set(ctx, "k2", 2, time.Minute))
+	// 覆盖不会增加计数
+	require.NoError(t, c.Set(ctx, "k2", 3, time.Minute))
+	assert.Equal(t, int32(2), c.cnt)
+
+	require.NoError(t, c.Delete(ctx, "k1"))
+	assert.Equal(t, int32(1), c.cnt)
+	_, err := c.LoadAndDelete(ctx, "k2")
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), c.cnt)
+}
+
+func TestMaxCntCache_GetReadLock(t *testing.T) {
+	// 读取不影响淘汰顺序的策略，Get 只需要读锁
+	for _, p := range []EvictionPolicy{NewFIFOPolicy(), NewRandomPolicy()} {
+		lc := NewLocalCache(time.Minute)
+		c := NewMaxCntCache(lc, 2, MaxCntCacheWithEvictionPolicy(p))
+		ctx := context.Background()
+		require.NoError(t, c.Set(ctx, "k1", 1, time.Minute))
+
+		c.mu.RLock()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			val, err := c.Get(ctx, "k1")
+			assert.NoError(t, err)
+			assert.Equal(t, 1, val)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Error("持有读锁的时候 Get 被阻塞了")
+		}
+		c.mu.RUnlock()
+		<-done
+		lc.Close()
+	}
+}
@@ -0,0 +1,188 @@
+package v3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+var (
+	errEntryTooLarge = errors.New("cache：entry larger than memory limit")
+	errUnsizedValue  = errors.New("cache：unable to compute value size")
+)
+
+// Sizer 计算一个值占用的字节数
+type Sizer func(val any) (int64, error)
+
+// MaxMemoryCache 按照占用的字节数限制缓存的大小，
+// 超过限制的时候按照淘汰策略淘汰，直到新的值能够放进去。
+type MaxMemoryCache struct {
+	*LocalCache
+	// 下面的字段都受 LocalCache.mu 保护
+	used  int64
+	sizes map[string]int64
+
+	maxMemory int64
+	sizer     Sizer
+	policy    EvictionPolicy
+	// recordReads 策略需要知道读操作，Get 的时候要拿写锁
+	recordReads bool
+}
+
+type MaxMemoryCacheOption func(c *MaxMemoryCache)
+
+// NewMaxMemoryCache 默认使用 LRU 淘汰策略，默认只支持 []byte 和 string 类型的值
+func NewMaxMemoryCache(c *LocalCache, maxMemory int64, opts ...MaxMemoryCacheOption) *MaxMemoryCache {
+	newCache := &MaxMemoryCache{
+		LocalCache: c,
+		sizes:      make(map[string]int64),
+		maxMemory:  maxMemory,
+		sizer:      DefaultSizer,
+		policy:     NewLRUPolicy(),
+	}
+
+	for _, opt := range opts {
+		opt(newCache)
+	}
+	_, ignore := newCache.policy.(readIgnoringPolicy)
+	newCache.recordReads = !ignore
+
+	removed := c.removed
+	newCache.removed = func(k string) {
+		newCache.used -= newCache.sizes[k]
+		delete(newCache.sizes, k)
+		newCache.policy.Remove(k)
+		if removed != nil {
+			removed(k)
+		}
+	}
+
+	newCache.adopt()
+	return newCache
+}
+
+// adopt 把 LocalCache 里面已有的数据纳入统计，例如从快照文件恢复的数据。
+// 算不出大小或者单个就超过限制的数据会被删除，超过容量的部分按照淘汰策略淘汰
+func (c *MaxMemoryCache) adopt() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, itm := range c.data {
+		size, err := c.size(k, itm.val)
+		if err != nil {
+			c.delete(k, EvictReasonCapacity)
+			continue
+		}
+		c.used += size
+		c.sizes[k] = size
+		c.policy.KeyAccessed(k)
+	}
+	for c.used > c.maxMemory {
+		victim, ok := c.policy.Evict()
+		if !ok {
+			return
+		}
+		c.delete(victim, EvictReasonCapacity)
+	}
+}
+
+// Restore 从 r 里面恢复数据，和 Set 一样受到容量的限制
+func (c *MaxMemoryCache) Restore(r io.Reader) error {
+	entries, err := c.readSnapshot(r)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var errs []error
+	for _, e := range entries {
+		size, err := c.size(e.key, e.val)
+		if err == nil {
+			err = c.setLocked(e.key, e.val, size, e.expiration)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func MaxMemoryCacheWithSizer(sizer Sizer) MaxMemoryCacheOption {
+	return func(c *MaxMemoryCache) {
+		c.sizer = sizer
+	}
+}
+
+func MaxMemoryCacheWithEvictionPolicy(p EvictionPolicy) MaxMemoryCacheOption {
+	return func(c *MaxMemoryCache) {
+		c.policy = p
+	}
+}
+
+// DefaultSizer 只能计算 string 和 []byte 的大小，其它类型返回错误
+func DefaultSizer(val any) (int64, error) {
+	switch v := val.(type) {
+	case []byte:
+		return int64(len(v)), nil
+	case string:
+		return int64(len(v)), nil
+	default:
+		return 0, fmt.Errorf("%w, type: %T", errUnsizedValue, val)
+	}
+}
+
+func (c *MaxMemoryCache) Set(ctx context.Context, k string, v any, expiration time.Duration) error {
+	size, err := c.size(k, v)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.setLocked(k, v, size, expiration)
+}
+
+func (c *MaxMemoryCache) size(k string, v any) (int64, error) {
+	size, err := c.sizer(v)
+	if err != nil {
+		return 0, err
+	}
+	if size > c.maxMemory {
+		return 0, fmt.Errorf("%w, key: %s, size: %d", errEntryTooLarge, k, size)
+	}
+	return size, nil
+}
+
+// setLocked 调用方需要持有写锁
+func (c *MaxMemoryCache) setLocked(k string, v any, size int64, expiration time.Duration) error {
+	// 覆盖的时候，旧的值占用的空间是可以释放的
+	for c.used-c.sizes[k]+size > c.maxMemory {
+		victim, ok := c.policy.Evict()
+		if !ok {
+			return fmt.Errorf("%w, key: %s", errOverCapacity, k)
+		}
+		// delete 会触发 removed，在里面维护 used
+		c.delete(victim, EvictReasonCapacity)
+	}
+
+	c.used += size - c.sizes[k]
+	c.sizes[k] = size
+	c.set(k, v, expiration)
+	c.stats.RecordSet()
+	c.policy.KeyAccessed(k)
+	return nil
+}
+
+func (c *MaxMemoryCache) Get(ctx context.Context, k string) (any, error) {
+	val, err := c.LocalCache.Get(ctx, k)
+	if err != nil || !c.recordReads {
+		return val, err
+	}
+	c.mu.Lock()
+	if _, ok := c.data[k]; ok {
+		c.policy.KeyAccessed(k)
+	}
+	c.mu.Unlock()
+	return val, nil
+}
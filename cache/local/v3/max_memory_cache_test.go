@@ -0,0 +1,139 @@
+package v3
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxMemoryCache_Set(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts []MaxMemoryCacheOption
+		key  string
+		val  any
+
+		wantErr     error
+		wantEvicted []string
+		wantUsed    int64
+	}{
+		{
+			name:     "enough memory",
+			key:      "k4",
+			val:      "a",
+			wantUsed: 10,
+		},
+		{
+			name:        "evict one",
+			key:         "k4",
+			val:         "abc",
+			wantEvicted: []string{"k1"},
+			wantUsed:    10,
+		},
+		{
+			name:        "evict many",
+			key:         "k4",
+			val:         []byte("abcdef"),
+			wantEvicted: []string{"k1", "k2"},
+			wantUsed:    10,
+		},
+		{
+			name: "overwrite",
+			key:  "k2",
+			val:  "abcd",
+			// 旧的值被覆盖也会通知
+			wantEvicted: []string{"k2"},
+			wantUsed:    10,
+		},
+		{
+			name:    "too large",
+			key:     "k4",
+			val:     "abcdefghijk",
+			wantErr: errEntryTooLarge,
+			// 不会淘汰任何东西
+			wantUsed: 9,
+		},
+		{
+			name:     "unsized value",
+			key:      "k4",
+			val:      123,
+			wantErr:  errUnsizedValue,
+			wantUsed: 9,
+		},
+		{
+			name: "custom sizer",
+			opts: []MaxMemoryCacheOption{MaxMemoryCacheWithSizer(func(val any) (int64, error) {
+				return 1, nil
+			})},
+			key:      "k4",
+			val:      123,
+			wantUsed: 4,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var evicted []string
+			lc := NewLocalCache(time.Minute, LocalCacheWithEvictedCallback(func(k string, v any) {
+				evicted = append(evicted, k)
+			}))
+			defer lc.Close()
+			c := NewMaxMemoryCache(lc, 10, tc.opts...)
+			ctx := context.Background()
+			require.NoError(t, c.Set(ctx, "k1", "ab", time.Minute))
+			require.NoError(t, c.Set(ctx, "k2", "abc", time.Minute))
+			require.NoError(t, c.Set(ctx, "k3", "abcd", time.Minute))
+
+			err := c.Set(ctx, tc.key, tc.val, time.Minute)
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.wantEvicted, evicted)
+			assert.Equal(t, tc.wantUsed, c.used)
+		})
+	}
+}
+
+func TestMaxMemoryCache_Delete(t *testing.T) {
+	lc := NewLocalCache(time.Minute)
+	defer lc.Close()
+	c := NewMaxMemoryCache(lc, 10)
+	ctx := context.Background()
+	require.NoError(t, c.Set(ctx, "k1", "ab", time.Minute))
+	require.NoError(t, c.Set(ctx, "k2", "abc", time.Minute))
+
+	require.NoError(t, c.Delete(ctx, "k1"))
+	assert.Equal(t, int64(3), c.used)
+	_, err := c.LoadAndDelete(ctx, "k2")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), c.used)
+	assert.Empty(t, c.sizes)
+}
+
+func TestMaxMemoryCache_GetReadLock(t *testing.T) {
+	// 读取不影响淘汰顺序的策略，Get 只需要读锁
+	for _, p := range []EvictionPolicy{NewFIFOPolicy(), NewRandomPolicy()} {
+		lc := NewLocalCache(time.Minute)
+		c := NewMaxMemoryCache(lc, 10, MaxMemoryCacheWithEvictionPolicy(p))
+		ctx := context.Background()
+		require.NoError(t, c.Set(ctx, "k1", "v1", time.Minute))
+
+		c.mu.RLock()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			val, err := c.Get(ctx, "k1")
+			assert.NoError(t, err)
+			assert.Equal(t, "v1", val)
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Error("持有读锁的时候 Get 被阻塞了")
+		}
+		c.mu.RUnlock()
+		<-done
+		lc.Close()
+	}
+}
@@ -0,0 +1,90 @@
+package v3
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/luxpo/time-go2nd/cache/stats"
+)
+
+// ShardedLocalCache 把 key 按照哈希分散到多个 LocalCache 上，
+// 每个分片有自己的锁和自己的过期清理 goroutine，
+// 这样高并发下不会所有 goroutine 都争抢同一把锁。
+type ShardedLocalCache struct {
+	shards []*LocalCache
+}
+
+// NewShardedLocalCache shardCnt 是分片数量，opts 会应用到每一个分片上。
+// 为了避免所有分片同时扫描，各个分片的清理 goroutine 会错开启动。
+// 设置了 LocalCacheWithSnapshotFile 的时候，每个分片使用自己的快照文件 path.shard-i，
+// 分片数量变了之后旧的快照文件就对不上了。
+func NewShardedLocalCache(shardCnt int, interval time.Duration, opts ...LocalCacheOption) *ShardedLocalCache {
+	if shardCnt < 1 {
+		shardCnt = 1
+	}
+	shards := make([]*LocalCache, shardCnt)
+	for i := range shards {
+		delay := interval * time.Duration(i) / time.Duration(shardCnt)
+		shardOpts := append(opts[:len(opts):len(opts)], shardSnapshotPath(i))
+		shards[i] = newLocalCache(interval, delay, shardOpts...)
+	}
+	return &ShardedLocalCache{
+		shards: shards,
+	}
+}
+
+func (c *ShardedLocalCache) Set(ctx context.Context, k string, v any, expiration time.Duration) error {
+	return c.shard(k).Set(ctx, k, v, expiration)
+}
+
+func (c *ShardedLocalCache) Get(ctx context.Context, k string) (any, error) {
+	return c.shard(k).Get(ctx, k)
+}
+
+func (c *ShardedLocalCache) Delete(ctx context.Context, key string) error {
+	return c.shard(key).Delete(ctx, key)
+}
+
+func (c *ShardedLocalCache) LoadAndDelete(ctx context.Context, key string) (any, error) {
+	return c.shard(key).LoadAndDelete(ctx, key)
+}
+
+func (c *ShardedLocalCache) Close() error {
+	for _, s := range c.shards {
+		_ = s.Close()
+	}
+	return nil
+}
+
+// Stats 汇总所有分片的统计数据
+func (c *ShardedLocalCache) Stats() stats.Stats {
+	var s stats.Stats
+	for _, shard := range c.shards {
+		s.Merge(shard.Stats())
+	}
+	return s
+}
+
+// shardSnapshotPath 要放在所有 opts 后面，让各个分片的快照不会互相覆盖
+func shardSnapshotPath(i int) LocalCacheOption {
+	return func(cache *LocalCache) {
+		if cache.snapshotPath != "" {
+			cache.snapshotPath = fmt.Sprintf("%s.shard-%d", cache.snapshotPath, i)
+		}
+	}
+}
+
+// shard 按照 key 的 FNV-1a 哈希选择分片
+func (c *ShardedLocalCache) shard(key string) *LocalCache {
+	const (
+		offset32 = 2166136261
+		prime32  = 16777619
+	)
+	h := uint32(offset32)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= prime32
+	}
+	return c.shards[h%uint32(len(c.shards))]
+}
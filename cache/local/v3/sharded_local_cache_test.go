@@ -0,0 +1,101 @@
+package v3
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/luxpo/time-go2nd/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardedLocalCache(t *testing.T) {
+	var evicted atomic.Int32
+	clk := clock.NewFakeClock(time.Unix(0, 0))
+	c := NewShardedLocalCache(8, time.Minute, LocalCacheWithClock(clk),
+		LocalCacheWithEvictedCallback(func(k string, v any) {
+			evicted.Add(1)
+		}))
+	defer c.Close()
+	ctx := context.Background()
+
+	for i := 0; i < 100; i++ {
+		k := strconv.Itoa(i)
+		require.NoError(t, c.Set(ctx, k, i, time.Minute))
+	}
+	for i := 0; i < 100; i++ {
+		val, err := c.Get(ctx, strconv.Itoa(i))
+		require.NoError(t, err)
+		assert.Equal(t, i, val)
+	}
+
+	val, err := c.LoadAndDelete(ctx, "1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, val)
+	assert.Equal(t, int32(1), evicted.Load())
+	_, err = c.Get(ctx, "1")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+
+	require.NoError(t, c.Delete(ctx, "2"))
+	_, err = c.Get(ctx, "2")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	assert.Equal(t, int32(2), evicted.Load())
+
+	require.NoError(t, c.Set(ctx, "expired", 1, time.Millisecond))
+	clk.Advance(time.Millisecond * 10)
+	_, err = c.Get(ctx, "expired")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	assert.Equal(t, int32(3), evicted.Load())
+}
+
+// 在 64 个以上的 goroutine 下对比单锁和分片锁
+func BenchmarkLocalCache_Parallel(b *testing.B) {
+	c := NewLocalCache(time.Minute)
+	defer c.Close()
+	benchmarkParallel(b, c)
+}
+
+func BenchmarkShardedLocalCache_Parallel(b *testing.B) {
+	for _, shardCnt := range []int{16, 64, 256} {
+		b.Run(fmt.Sprintf("shards-%d", shardCnt), func(b *testing.B) {
+			c := NewShardedLocalCache(shardCnt, time.Minute)
+			defer c.Close()
+			benchmarkParallel(b, c)
+		})
+	}
+}
+
+type benchCache interface {
+	Set(ctx context.Context, k string, v any, expiration time.Duration) error
+	Get(ctx context.Context, k string) (any, error)
+}
+
+func benchmarkParallel(b *testing.B, c benchCache) {
+	ctx := context.Background()
+	const keyCnt = 1 << 12
+	keys := make([]string, keyCnt)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+		_ = c.Set(ctx, keys[i], i, time.Hour)
+	}
+	// 至少 64 个 goroutine
+	b.SetParallelism(64)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			k := keys[i%keyCnt]
+			// 读多写少，每 8 次操作一次写
+			if i%8 == 0 {
+				_ = c.Set(ctx, k, i, time.Hour)
+			} else {
+				_, _ = c.Get(ctx, k)
+			}
+			i++
+		}
+	})
+}
@@ -0,0 +1,252 @@
+package v3
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/luxpo/time-go2nd/micro/rpc2/serialize"
+)
+
+// snapshotVersion 快照格式的版本，格式变了之后要加一
+const snapshotVersion = 1
+
+// maxSnapshotPrealloc 快照里面的数量不可信，预分配的时候最多按照这个数量
+const maxSnapshotPrealloc = 1024
+
+var (
+	errUnsupportedSnapshot = errors.New("cache：unsupported snapshot version")
+	errCorruptedSnapshot   = errors.New("cache：corrupted snapshot")
+)
+
+// ValueCodec 负责快照里面值的编解码
+type ValueCodec interface {
+	Encode(val any) ([]byte, error)
+	Decode(data []byte) (any, error)
+}
+
+// GobCodec 是默认的编解码方式。
+// 自定义的类型需要先调用 gob.Register 注册。
+type GobCodec struct{}
+
+type gobValue struct {
+	Val any
+}
+
+func (GobCodec) Encode(val any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(gobValue{Val: val}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec) Decode(data []byte) (any, error) {
+	var v gobValue
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v); err != nil {
+		return nil, err
+	}
+	return v.Val, nil
+}
+
+// SerializerCodec 复用 rpc 里面的 serialize.Serializer。
+// 因为 Decode 需要知道目标类型，所以缓存里面的值都必须是同一种类型，
+// newVal 返回用来解码的指针，恢复之后缓存里面存的就是这个指针。
+type SerializerCodec struct {
+	s      serialize.Serializer
+	newVal func() any
+}
+
+func NewSerializerCodec(s serialize.Serializer, newVal func() any) *SerializerCodec {
+	return &SerializerCodec{
+		s:      s,
+		newVal: newVal,
+	}
+}
+
+func (c *SerializerCodec) Encode(val any) ([]byte, error) {
+	return c.s.Encode(val)
+}
+
+func (c *SerializerCodec) Decode(data []byte) (any, error) {
+	val := c.newVal()
+	if err := c.s.Decode(data, val); err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+type snapshotHeader struct {
+	Version int
+	Cnt     int
+}
+
+type snapshotEntry struct {
+	Key string
+	Val []byte
+	// Deadline 是过期时间，零值表示永不过期。
+	// 用绝对时间，进程停止的这段时间也算在过期时间里面
+	Deadline time.Time
+}
+
+// restoredEntry 是解码之后的数据，expiration 是相对恢复的时候剩余的过期时间
+type restoredEntry struct {
+	key        string
+	val        any
+	expiration time.Duration
+}
+
+// LocalCacheWithValueCodec 设置快照使用的编解码方式，默认是 GobCodec
+func LocalCacheWithValueCodec(codec ValueCodec) LocalCacheOption {
+	return func(cache *LocalCache) {
+		cache.codec = codec
+	}
+}
+
+// LocalCacheWithSnapshotFile 启动的时候从 path 恢复数据，恢复失败的时候从空缓存开始；
+// 之后每隔 interval 写一次快照，Close 的时候也会写一次。
+// interval 小于等于 0 的时候只在 Close 的时候写。
+func LocalCacheWithSnapshotFile(path string, interval time.Duration) LocalCacheOption {
+	return func(cache *LocalCache) {
+		cache.snapshotPath = path
+		cache.snapshotInterval = interval
+	}
+}
+
+// Snapshot 把没有过期的数据写到 w 里面，过期时间保存为绝对时间
+func (c *LocalCache) Snapshot(w io.Writer) error {
+	now := c.clock.Now()
+	c.mu.RLock()
+	entries := make([]snapshotEntry, 0, len(c.data))
+	vals := make([]any, 0, len(c.data))
+	for k, itm := range c.data {
+		if itm.deadlineBeforeNow(now) {
+			continue
+		}
+		entries = append(entries, snapshotEntry{Key: k, Deadline: itm.deadline})
+		vals = append(vals, itm.val)
+	}
+	c.mu.RUnlock()
+
+	// 编码比较慢，放在锁外面
+	for i := range entries {
+		data, err := c.codec.Encode(vals[i])
+		if err != nil {
+			return fmt.Errorf("cache：encode key %s: %w", entries[i].Key, err)
+		}
+		entries[i].Val = data
+	}
+
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(snapshotHeader{Version: snapshotVersion, Cnt: len(entries)}); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Restore 从 r 里面恢复数据，已经存在的 key 会被覆盖。
+// 数据是直接写到 LocalCache 里面的，不经过 MaxCntCache 之类的容量控制，
+// 需要容量控制的时候调用装饰器自己的 Restore。
+func (c *LocalCache) Restore(r io.Reader) error {
+	entries, err := c.readSnapshot(r)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range entries {
+		c.set(e.key, e.val, e.expiration)
+	}
+	return nil
+}
+
+// readSnapshot 解码快照，已经过期的数据会被跳过
+func (c *LocalCache) readSnapshot(r io.Reader) ([]restoredEntry, error) {
+	dec := gob.NewDecoder(r)
+	var header snapshotHeader
+	if err := dec.Decode(&header); err != nil {
+		return nil, err
+	}
+	if header.Version != snapshotVersion {
+		return nil, fmt.Errorf("%w: %d", errUnsupportedSnapshot, header.Version)
+	}
+	if header.Cnt < 0 {
+		return nil, fmt.Errorf("%w: invalid count %d", errCorruptedSnapshot, header.Cnt)
+	}
+
+	now := c.clock.Now()
+	capacity := header.Cnt
+	if capacity > maxSnapshotPrealloc {
+		capacity = maxSnapshotPrealloc
+	}
+	entries := make([]restoredEntry, 0, capacity)
+	for i := 0; i < header.Cnt; i++ {
+		var e snapshotEntry
+		if err := dec.Decode(&e); err != nil {
+			return nil, fmt.Errorf("%w: %w", errCorruptedSnapshot, err)
+		}
+		var expiration time.Duration
+		if !e.Deadline.IsZero() {
+			expiration = e.Deadline.Sub(now)
+			if expiration <= 0 {
+				continue
+			}
+		}
+		val, err := c.codec.Decode(e.Val)
+		if err != nil {
+			return nil, fmt.Errorf("cache：decode key %s: %w", e.Key, err)
+		}
+		entries = append(entries, restoredEntry{key: e.Key, val: val, expiration: expiration})
+	}
+	return entries, nil
+}
+
+func (c *LocalCache) snapshotToFile() error {
+	// 先写临时文件再重命名，避免进程崩溃的时候留下写了一半的快照
+	tmp, err := os.CreateTemp(filepath.Dir(c.snapshotPath), filepath.Base(c.snapshotPath)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err = c.Snapshot(tmp); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.snapshotPath)
+}
+
+func (c *LocalCache) restoreFromFile() error {
+	f, err := os.Open(c.snapshotPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return c.Restore(f)
+}
+
+func (c *LocalCache) snapshotLoop() {
+	defer close(c.snapshotDone)
+	ticker := c.clock.NewTicker(c.snapshotInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+			_ = c.snapshotToFile()
+		case <-c.close:
+			return
+		}
+	}
+}
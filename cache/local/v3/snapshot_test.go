@@ -0,0 +1,282 @@
+package v3
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/luxpo/time-go2nd/clock"
+	"github.com/luxpo/time-go2nd/micro/rpc2/serialize/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type snapshotUser struct {
+	Name string
+	Age  int
+}
+
+func TestLocalCache_SnapshotRestore(t *testing.T) {
+	testCases := []struct {
+		name  string
+		codec ValueCodec
+		vals  map[string]any
+	}{
+		{
+			name:  "gob",
+			codec: GobCodec{},
+			vals: map[string]any{
+				"k1": "v1",
+				"k2": 123,
+				"k3": []byte("v3"),
+			},
+		},
+		{
+			name: "serializer",
+			codec: NewSerializerCodec(&json.Serializer{}, func() any {
+				return &snapshotUser{}
+			}),
+			vals: map[string]any{
+				"k1": &snapshotUser{Name: "Tom", Age: 18},
+				"k2": &snapshotUser{Name: "Jerry", Age: 20},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			clk := clock.NewFakeClock(time.Unix(0, 0))
+			src := NewLocalCache(time.Minute, LocalCacheWithValueCodec(tc.codec), LocalCacheWithClock(clk))
+			defer src.Close()
+			for k, v := range tc.vals {
+				require.NoError(t, src.Set(ctx, k, v, time.Minute))
+			}
+			require.NoError(t, src.Set(ctx, "forever", tc.vals["k1"], 0))
+			require.NoError(t, src.Set(ctx, "expired", tc.vals["k1"], time.Millisecond))
+			clk.Advance(time.Millisecond * 5)
+
+			var buf bytes.Buffer
+			require.NoError(t, src.Snapshot(&buf))
+
+			dst := NewLocalCache(time.Minute, LocalCacheWithValueCodec(tc.codec), LocalCacheWithClock(clk))
+			defer dst.Close()
+			require.NoError(t, dst.Restore(&buf))
+
+			for k, v := range tc.vals {
+				val, err := dst.Get(ctx, k)
+				require.NoError(t, err)
+				assert.Equal(t, v, val)
+				// 剩余的过期时间被保留下来了
+				assert.Equal(t, src.data[k].deadline, dst.data[k].deadline)
+			}
+			assert.True(t, dst.data["forever"].deadline.IsZero())
+			_, ok := dst.data["expired"]
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestLocalCache_SnapshotFile(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+
+	c := NewLocalCache(time.Minute, LocalCacheWithSnapshotFile(path, 0))
+	require.NoError(t, c.Set(ctx, "k1", "v1", time.Minute))
+	require.NoError(t, c.Close())
+
+	// 重启之后数据还在
+	clk := clock.NewFakeClock(time.Now())
+	c = NewLocalCache(time.Minute, LocalCacheWithClock(clk), LocalCacheWithSnapshotFile(path, time.Second))
+	val, err := c.Get(ctx, "k1")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", val)
+
+	require.NoError(t, c.Set(ctx, "k2", "v2", time.Minute))
+	// 等清理和定时快照的 goroutine 都创建好 ticker
+	require.Eventually(t, func() bool {
+		return clk.Waiters() == 2
+	}, time.Second, time.Millisecond)
+	clk.Advance(time.Second)
+	// 定时写的快照
+	assert.Eventually(t, func() bool {
+		f, err := os.Open(path)
+		if err != nil {
+			return false
+		}
+		defer f.Close()
+		restored := NewLocalCache(time.Minute, LocalCacheWithClock(clk))
+		defer restored.Close()
+		if restored.Restore(f) != nil {
+			return false
+		}
+		val, err := restored.Get(ctx, "k2")
+		return err == nil && val == "v2"
+	}, time.Second, time.Millisecond)
+	require.NoError(t, c.Close())
+}
+
+func TestLocalCache_CloseWaitsForSnapshotLoop(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	c := NewLocalCache(time.Minute, LocalCacheWithSnapshotFile(path, time.Millisecond))
+	require.NoError(t, c.Set(ctx, "k1", "v1", time.Minute))
+	require.NoError(t, c.Close())
+
+	// Close 返回的时候定时快照已经停了，最后写的是 Close 的快照
+	select {
+	case <-c.snapshotDone:
+	default:
+		t.Fatal("定时快照的 goroutine 还在运行")
+	}
+	restored := NewLocalCache(time.Minute, LocalCacheWithSnapshotFile(path, 0))
+	defer restored.Close()
+	val, err := restored.Get(ctx, "k1")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", val)
+}
+
+func TestLocalCache_RestoreDeadline(t *testing.T) {
+	ctx := context.Background()
+	start := time.Unix(0, 0)
+	src := NewLocalCache(time.Minute, LocalCacheWithClock(clock.NewFakeClock(start)))
+	defer src.Close()
+	require.NoError(t, src.Set(ctx, "k1", "v1", time.Second*10))
+	require.NoError(t, src.Set(ctx, "k2", "v2", time.Second*2))
+
+	var buf bytes.Buffer
+	require.NoError(t, src.Snapshot(&buf))
+
+	// 停了 5 秒之后才恢复，停机的时间也要算在过期时间里面
+	dst := NewLocalCache(time.Minute, LocalCacheWithClock(clock.NewFakeClock(start.Add(time.Second*5))))
+	defer dst.Close()
+	require.NoError(t, dst.Restore(&buf))
+	assert.Equal(t, start.Add(time.Second*10), dst.data["k1"].deadline)
+	_, ok := dst.data["k2"]
+	assert.False(t, ok)
+}
+
+func TestLocalCache_RestoreCorrupted(t *testing.T) {
+	testCases := []struct {
+		name   string
+		header snapshotHeader
+	}{
+		{
+			name:   "negative count",
+			header: snapshotHeader{Version: snapshotVersion, Cnt: -1},
+		},
+		{
+			// 不能按照 Cnt 预分配，否则会 panic 或者把内存用光
+			name:   "huge count",
+			header: snapshotHeader{Version: snapshotVersion, Cnt: 1 << 62},
+		},
+		{
+			name:   "truncated",
+			header: snapshotHeader{Version: snapshotVersion, Cnt: 2},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := gob.NewEncoder(&buf)
+			require.NoError(t, enc.Encode(tc.header))
+			val, err := GobCodec{}.Encode("v1")
+			require.NoError(t, err)
+			require.NoError(t, enc.Encode(snapshotEntry{Key: "k1", Val: val}))
+
+			c := NewLocalCache(time.Minute)
+			defer c.Close()
+			err = c.Restore(&buf)
+			assert.ErrorIs(t, err, errCorruptedSnapshot)
+			assert.Empty(t, c.data)
+		})
+	}
+}
+
+func TestShardedLocalCache_SnapshotFile(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cache.snapshot")
+	keys := []string{"k1", "k2", "k3", "k4", "k5", "k6", "k7", "k8"}
+
+	c := NewShardedLocalCache(4, time.Minute, LocalCacheWithSnapshotFile(path, 0))
+	for _, k := range keys {
+		require.NoError(t, c.Set(ctx, k, k, time.Minute))
+	}
+	require.NoError(t, c.Close())
+
+	// 每个分片写自己的文件，不会互相覆盖
+	for i := 0; i < 4; i++ {
+		_, err := os.Stat(fmt.Sprintf("%s.shard-%d", path, i))
+		require.NoError(t, err)
+	}
+	c = NewShardedLocalCache(4, time.Minute, LocalCacheWithSnapshotFile(path, 0))
+	defer c.Close()
+	for _, k := range keys {
+		val, err := c.Get(ctx, k)
+		require.NoError(t, err)
+		assert.Equal(t, k, val)
+	}
+}
+
+func TestMaxCntCache_Restore(t *testing.T) {
+	ctx := context.Background()
+	src := NewLocalCache(time.Minute)
+	defer src.Close()
+	for _, k := range []string{"k1", "k2", "k3"} {
+		require.NoError(t, src.Set(ctx, k, k, time.Minute))
+	}
+	var buf bytes.Buffer
+	require.NoError(t, src.Snapshot(&buf))
+	data := buf.Bytes()
+
+	// 恢复的数据也要计数，超过容量的会被淘汰
+	c := NewMaxCntCache(NewLocalCache(time.Minute), 2)
+	defer c.Close()
+	require.NoError(t, c.Restore(bytes.NewReader(data)))
+	assert.Equal(t, int32(2), c.cnt)
+	assert.Len(t, c.data, 2)
+
+	// 先恢复再包装也一样
+	lc := NewLocalCache(time.Minute)
+	require.NoError(t, lc.Restore(bytes.NewReader(data)))
+	c = NewMaxCntCache(lc, 2)
+	defer c.Close()
+	assert.Equal(t, int32(2), c.cnt)
+	assert.Len(t, c.data, 2)
+	require.NoError(t, c.Set(ctx, "k4", "k4", time.Minute))
+	assert.Equal(t, int32(2), c.cnt)
+	assert.Len(t, c.data, 2)
+}
+
+func TestMaxMemoryCache_Restore(t *testing.T) {
+	ctx := context.Background()
+	src := NewLocalCache(time.Minute)
+	defer src.Close()
+	require.NoError(t, src.Set(ctx, "k1", "12345", time.Minute))
+	require.NoError(t, src.Set(ctx, "k2", "12345", time.Minute))
+	require.NoError(t, src.Set(ctx, "k3", 123, time.Minute))
+	var buf bytes.Buffer
+	require.NoError(t, src.Snapshot(&buf))
+	data := buf.Bytes()
+
+	c := NewMaxMemoryCache(NewLocalCache(time.Minute), 8)
+	defer c.Close()
+	// k3 算不出大小
+	assert.ErrorIs(t, c.Restore(bytes.NewReader(data)), errUnsizedValue)
+	assert.Equal(t, int64(5), c.used)
+	assert.Len(t, c.data, 1)
+
+	lc := NewLocalCache(time.Minute)
+	require.NoError(t, lc.Restore(bytes.NewReader(data)))
+	c = NewMaxMemoryCache(lc, 8)
+	defer c.Close()
+	assert.Equal(t, int64(5), c.used)
+	assert.Len(t, c.data, 1)
+	assert.Len(t, c.sizes, 1)
+}
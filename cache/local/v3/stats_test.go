@@ -0,0 +1,50 @@
+package v3
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/luxpo/time-go2nd/cache/stats"
+	"github.com/luxpo/time-go2nd/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxCntCache_Stats(t *testing.T) {
+	clk := clock.NewFakeClock(time.Unix(0, 0))
+	lc := NewLocalCache(time.Minute, LocalCacheWithClock(clk))
+	defer lc.Close()
+	c := NewMaxCntCache(lc, 2)
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, "k1", 1, time.Minute))
+	require.NoError(t, c.Set(ctx, "k1", 2, time.Minute))
+	require.NoError(t, c.Set(ctx, "k2", 1, time.Millisecond))
+	clk.Advance(time.Millisecond * 5)
+	_, err := c.Get(ctx, "k1")
+	require.NoError(t, err)
+	_, err = c.Get(ctx, "k2")
+	assert.Error(t, err)
+	require.NoError(t, c.Set(ctx, "k3", 1, time.Minute))
+	require.NoError(t, c.Set(ctx, "k4", 1, time.Minute))
+	require.NoError(t, c.Delete(ctx, "k4"))
+	// 不存在的 key 不算删除
+	require.NoError(t, c.Delete(ctx, "k4"))
+	_, err = c.LoadAndDelete(ctx, "k4")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+
+	assert.Equal(t, stats.Stats{
+		Hits:        1,
+		Misses:      1,
+		Sets:        5,
+		Deletes:     1,
+		Expirations: 1,
+		Evictions: map[string]uint64{
+			"replaced": 1,
+			"capacity": 1,
+			"explicit": 1,
+		},
+		Size: 1,
+	}, c.Stats())
+}
@@ -0,0 +1,123 @@
+package v3
+
+import "time"
+
+const (
+	wheelBits   = 6
+	wheelSize   = 1 << wheelBits
+	wheelMask   = wheelSize - 1
+	wheelLevels = 5
+)
+
+// timingWheel 是一个分层时间轮，用来驱动 key 的过期。
+// 第 0 层每个槽代表一个 tick，第 l 层每个槽代表 64^l 个 tick，
+// 上层的槽到期之后会把里面的 key 重新分配到下层（cascade）。
+// 添加、删除都是 O(1) 的，每个 tick 只需要处理真正到期的 key，
+// 不需要像之前那样随机扫描整个 map。
+// timingWheel 不是并发安全的，由 LocalCache 在持有写锁的时候调用。
+type timingWheel struct {
+	tick  time.Duration
+	start time.Time
+	// current 是已经处理到的 tick
+	current uint64
+
+	buckets [wheelLevels][wheelSize]map[string]struct{}
+	entries map[string]*wheelEntry
+}
+
+type wheelEntry struct {
+	expireTick uint64
+	level      int
+	slot       int
+}
+
+func newTimingWheel(tick time.Duration, start time.Time) *timingWheel {
+	return &timingWheel{
+		tick:    tick,
+		start:   start,
+		entries: make(map[string]*wheelEntry),
+	}
+}
+
+// add 添加或者更新 key 的过期时间，key 会在 deadline 之后的一个 tick 内过期
+func (w *timingWheel) add(key string, deadline time.Time) {
+	w.remove(key)
+	d := deadline.Sub(w.start)
+	expireTick := uint64(0)
+	if d > 0 {
+		// 向上取整，保证不会提前过期
+		expireTick = uint64((d + w.tick - 1) / w.tick)
+	}
+	if expireTick <= w.current {
+		expireTick = w.current + 1
+	}
+	e := &wheelEntry{expireTick: expireTick}
+	w.entries[key] = e
+	w.place(key, e)
+}
+
+func (w *timingWheel) remove(key string) {
+	e, ok := w.entries[key]
+	if !ok {
+		return
+	}
+	delete(w.buckets[e.level][e.slot], key)
+	delete(w.entries, key)
+}
+
+// advance 推进到 now，返回所有到期的 key
+func (w *timingWheel) advance(now time.Time) []string {
+	if now.Before(w.start) {
+		return nil
+	}
+	target := uint64(now.Sub(w.start) / w.tick)
+	var expired []string
+	for w.current < target {
+		w.current++
+		w.cascade()
+		slot := int(w.current & wheelMask)
+		for key := range w.buckets[0][slot] {
+			expired = append(expired, key)
+			delete(w.entries, key)
+		}
+		w.buckets[0][slot] = nil
+	}
+	return expired
+}
+
+// cascade 从高层到低层，把当前到期的槽里面的 key 重新分配
+func (w *timingWheel) cascade() {
+	for l := wheelLevels - 1; l > 0; l-- {
+		// 只有低层全部转完一圈才轮到这一层
+		if w.current&(1<<(wheelBits*l)-1) != 0 {
+			continue
+		}
+		slot := int((w.current >> (wheelBits * l)) & wheelMask)
+		bucket := w.buckets[l][slot]
+		w.buckets[l][slot] = nil
+		for key := range bucket {
+			w.place(key, w.entries[key])
+		}
+	}
+}
+
+// place 选择 expireTick 和 current 最高的不同的那一层，
+// 这样这个槽下一次被处理的时候，一定不会晚于 expireTick
+func (w *timingWheel) place(key string, e *wheelEntry) {
+	level := 0
+	for x := e.expireTick ^ w.current; x >= wheelSize; x >>= wheelBits {
+		level++
+	}
+	if level >= wheelLevels {
+		// 超出时间轮的范围，先放在最高层最后被处理的槽，到时候再重新分配
+		level = wheelLevels - 1
+		e.slot = int((w.current>>(wheelBits*level) - 1) & wheelMask)
+	} else {
+		e.slot = int((e.expireTick >> (wheelBits * level)) & wheelMask)
+	}
+	e.level = level
+	if w.buckets[level][e.slot] == nil {
+		w.buckets[level][e.slot] = make(map[string]struct{})
+	}
+	w.buckets[level][e.slot][key] = struct{}{}
+}
@@ -0,0 +1,124 @@
+package v3
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/luxpo/time-go2nd/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimingWheel_Advance(t *testing.T) {
+	start := time.Unix(0, 0)
+	testCases := []struct {
+		name    string
+		current uint64
+		// 相对 start 的过期时间
+		deadline time.Duration
+
+		wantTick uint64
+	}{
+		{
+			name:     "next tick",
+			deadline: time.Millisecond,
+			wantTick: 1,
+		},
+		{
+			name:     "already expired",
+			current:  10,
+			deadline: time.Second,
+			wantTick: 11,
+		},
+		{
+			name:     "level 0",
+			deadline: 63 * time.Second,
+			wantTick: 63,
+		},
+		{
+			name:     "cross level 0",
+			current:  63,
+			deadline: 65 * time.Second,
+			wantTick: 65,
+		},
+		{
+			name:     "level 1",
+			deadline: 1000*time.Second + time.Millisecond,
+			wantTick: 1001,
+		},
+		{
+			name:     "level 2",
+			current:  100,
+			deadline: 300000 * time.Second,
+			wantTick: 300000,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := newTimingWheel(time.Second, start)
+			w.current = tc.current
+			w.add("k", start.Add(tc.deadline))
+			w.add("removed", start.Add(tc.deadline))
+			w.remove("removed")
+
+			for tick := tc.current + 1; ; tick++ {
+				expired := w.advance(start.Add(time.Duration(tick) * time.Second))
+				if len(expired) > 0 {
+					assert.Equal(t, []string{"k"}, expired)
+					assert.Equal(t, tc.wantTick, tick)
+					break
+				}
+				require.Less(t, tick, tc.wantTick)
+			}
+			assert.Empty(t, w.entries)
+		})
+	}
+}
+
+func TestTimingWheel_Overflow(t *testing.T) {
+	start := time.Unix(0, 0)
+	w := newTimingWheel(time.Second, start)
+	w.add("k", start.Add(time.Duration(1)<<(wheelBits*wheelLevels)*time.Second))
+	w.add("k1", start.Add(2*time.Second))
+	w.add("k1", start.Add(3*time.Second))
+
+	assert.Empty(t, w.advance(start.Add(2*time.Second)))
+	assert.Equal(t, []string{"k1"}, w.advance(start.Add(3*time.Second)))
+	e := w.entries["k"]
+	require.NotNil(t, e)
+	assert.Equal(t, wheelLevels-1, e.level)
+}
+
+func TestLocalCache_Expire(t *testing.T) {
+	var cnt atomic.Int32
+	clk := clock.NewFakeClock(time.Unix(0, 0))
+	ticked := make(chan struct{}, 1)
+	c := NewLocalCache(time.Millisecond*10, LocalCacheWithClock(clk),
+		localCacheWithTickHook(func() {
+			ticked <- struct{}{}
+		}),
+		LocalCacheWithEvictedCallback(func(k string, v any) {
+			cnt.Add(1)
+		}))
+	defer c.Close()
+	ctx := context.Background()
+	require.NoError(t, c.Set(ctx, "k1", 1, time.Millisecond*20))
+	require.NoError(t, c.Set(ctx, "k2", 2, time.Minute))
+	require.NoError(t, c.Set(ctx, "k3", 3, 0))
+
+	// 等清理 goroutine 创建好 ticker
+	require.Eventually(t, func() bool {
+		return clk.Waiters() == 1
+	}, time.Second, time.Millisecond)
+	clk.Advance(time.Millisecond * 30)
+	<-ticked
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.data["k1"]
+	assert.False(t, ok)
+	assert.Len(t, c.data, 2)
+	assert.Equal(t, int32(1), cnt.Load())
+}
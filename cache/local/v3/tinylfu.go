@@ -0,0 +1,225 @@
+package v3
+
+import (
+	"container/list"
+	"hash/fnv"
+)
+
+var _ EvictionPolicy = (*WTinyLFUPolicy)(nil)
+
+// WTinyLFUPolicy 是 W-TinyLFU 的简化实现。
+// 新的 key 先进入一个很小的 window LRU，
+// 淘汰的时候拿 window 里面最老的 key 和 main LRU 里面最老的 key 比较访问频率，
+// 只有比 main 里面的更"热"才能挤进去，否则直接被淘汰。
+// 这样一次性扫描大量冷 key 的时候，它们只会在 window 里面打转，不会把热点数据挤出去。
+// 通过 MaxCntCacheWithEvictionPolicy 启用。
+type WTinyLFUPolicy struct {
+	windowCap int
+	window    *list.List
+	main      *list.List
+	nodes     map[string]*tinyLFUNode
+
+	sketch *countMinSketch
+}
+
+type tinyLFUNode struct {
+	elem     *list.Element
+	inWindow bool
+}
+
+// NewWTinyLFUPolicy capacity 是缓存的容量，一般和 MaxCntCache 的 maxCnt 一致。
+// window 占容量的 1%，至少是 1
+func NewWTinyLFUPolicy(capacity int) *WTinyLFUPolicy {
+	windowCap := capacity / 100
+	if windowCap < 1 {
+		windowCap = 1
+	}
+	return &WTinyLFUPolicy{
+		windowCap: windowCap,
+		window:    list.New(),
+		main:      list.New(),
+		nodes:     make(map[string]*tinyLFUNode),
+		sketch:    newCountMinSketch(capacity),
+	}
+}
+
+func (p *WTinyLFUPolicy) KeyAccessed(key string) {
+	p.sketch.increment(key)
+	if n, ok := p.nodes[key]; ok {
+		if n.inWindow {
+			p.window.MoveToFront(n.elem)
+		} else {
+			p.main.MoveToFront(n.elem)
+		}
+		return
+	}
+
+	p.nodes[key] = &tinyLFUNode{
+		elem:     p.window.PushFront(key),
+		inWindow: true,
+	}
+	// window 满了，把最老的挪到 main 里面。
+	// 总数是由 MaxCntCache 控制的，所以这里不需要淘汰
+	if p.window.Len() > p.windowCap {
+		e := p.window.Back()
+		k := p.window.Remove(e).(string)
+		p.nodes[k] = &tinyLFUNode{
+			elem: p.main.PushFront(k),
+		}
+	}
+}
+
+func (p *WTinyLFUPolicy) Evict() (string, bool) {
+	candidate := p.window.Back()
+	victim := p.main.Back()
+	switch {
+	case candidate == nil && victim == nil:
+		return "", false
+	case candidate == nil:
+		return p.removeElem(p.main, victim), true
+	case victim == nil:
+		return p.removeElem(p.window, candidate), true
+	}
+
+	ck, vk := candidate.Value.(string), victim.Value.(string)
+	if p.sketch.estimate(ck) <= p.sketch.estimate(vk) {
+		return p.removeElem(p.window, candidate), true
+	}
+	// candidate 更热，晋升到 main，淘汰 main 里面的 victim
+	p.window.Remove(candidate)
+	p.nodes[ck] = &tinyLFUNode{
+		elem: p.main.PushFront(ck),
+	}
+	return p.removeElem(p.main, victim), true
+}
+
+func (p *WTinyLFUPolicy) Remove(key string) {
+	n, ok := p.nodes[key]
+	if !ok {
+		return
+	}
+	if n.inWindow {
+		p.window.Remove(n.elem)
+	} else {
+		p.main.Remove(n.elem)
+	}
+	delete(p.nodes, key)
+}
+
+func (p *WTinyLFUPolicy) removeElem(l *list.List, e *list.Element) string {
+	key := l.Remove(e).(string)
+	delete(p.nodes, key)
+	return key
+}
+
+const (
+	cmsDepth = 4
+	// 计数器的上限，和 Caffeine 一样只用 4 位
+	cmsMaxCount = 15
+)
+
+// countMinSketch 用来估算 key 的访问频率。
+// 每累计 sampleSize 次访问就把所有计数器减半（aging），
+// 这样很久以前的热点会慢慢冷下来。
+// 前面还有一个 doorkeeper（一个简单的布隆过滤器），只访问过一次的 key 只会记录在 doorkeeper 里面，
+// 避免大量一次性的 key 把计数器撑满。
+type countMinSketch struct {
+	rows  [cmsDepth][]uint8
+	width uint64
+
+	doorkeeper []uint64
+
+	additions  int
+	sampleSize int
+}
+
+// newCountMinSketch 宽度取容量 4 倍以上的 2 的幂，减少冲突带来的误差
+func newCountMinSketch(capacity int) *countMinSketch {
+	width := 16
+	for width < capacity*4 {
+		width <<= 1
+	}
+	s := &countMinSketch{
+		width:      uint64(width),
+		sampleSize: 10 * width,
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	// 一个采样周期内 doorkeeper 最多写入 sampleSize 个 key，按每个 key 4 位估算
+	s.doorkeeper = make([]uint64, s.sampleSize*cmsDepth/64)
+	return s
+}
+
+func (s *countMinSketch) increment(key string) {
+	h1, h2 := s.hash(key)
+	if !s.doorkeeperContains(h1, h2) {
+		s.doorkeeperAdd(h1, h2)
+	} else {
+		for i := range s.rows {
+			idx := (h1 + uint64(i)*h2) % s.width
+			if s.rows[i][idx] < cmsMaxCount {
+				s.rows[i][idx]++
+			}
+		}
+	}
+	s.additions++
+	if s.additions >= s.sampleSize {
+		s.reset()
+	}
+}
+
+func (s *countMinSketch) estimate(key string) uint8 {
+	h1, h2 := s.hash(key)
+	res := uint8(cmsMaxCount)
+	for i := range s.rows {
+		idx := (h1 + uint64(i)*h2) % s.width
+		if s.rows[i][idx] < res {
+			res = s.rows[i][idx]
+		}
+	}
+	if res < cmsMaxCount && s.doorkeeperContains(h1, h2) {
+		res++
+	}
+	return res
+}
+
+// reset 计数器减半，doorkeeper 清空
+func (s *countMinSketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	for i := range s.doorkeeper {
+		s.doorkeeper[i] = 0
+	}
+	s.additions /= 2
+}
+
+func (s *countMinSketch) doorkeeperAdd(h1, h2 uint64) {
+	bits := uint64(len(s.doorkeeper)) * 64
+	for i := uint64(0); i < cmsDepth; i++ {
+		idx := (h1 + i*h2) % bits
+		s.doorkeeper[idx/64] |= 1 << (idx % 64)
+	}
+}
+
+func (s *countMinSketch) doorkeeperContains(h1, h2 uint64) bool {
+	bits := uint64(len(s.doorkeeper)) * 64
+	for i := uint64(0); i < cmsDepth; i++ {
+		idx := (h1 + i*h2) % bits
+		if s.doorkeeper[idx/64]&(1<<(idx%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *countMinSketch) hash(key string) (uint64, uint64) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	sum := h.Sum64()
+	// double hashing，h2 保证是奇数，避免多行落到同一个位置
+	return sum & 0xffffffff, (sum >> 32) | 1
+}
@@ -0,0 +1,91 @@
+package v3
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWTinyLFUPolicy_Scan(t *testing.T) {
+	// 同样的访问模式下 LRU 会把热点全部挤掉
+	assert.Less(t, hotHitsAfterScan(t, NewLRUPolicy()), 10)
+	// window 只有 1，最多损失一个热点
+	assert.GreaterOrEqual(t, hotHitsAfterScan(t, NewWTinyLFUPolicy(100)), 99)
+}
+
+func hotHitsAfterScan(t *testing.T, p EvictionPolicy) int {
+	lc := NewLocalCache(time.Minute)
+	defer lc.Close()
+	c := NewMaxCntCache(lc, 100, MaxCntCacheWithEvictionPolicy(p))
+	ctx := context.Background()
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, c.Set(ctx, fmt.Sprintf("hot-%d", i), i, time.Minute))
+	}
+	for j := 0; j < 3; j++ {
+		for i := 0; i < 100; i++ {
+			_, err := c.Get(ctx, fmt.Sprintf("hot-%d", i))
+			require.NoError(t, err)
+		}
+	}
+
+	// 扫描大量冷 key，期间热点依旧在被访问
+	for i := 0; i < 10000; i++ {
+		require.NoError(t, c.Set(ctx, fmt.Sprintf("cold-%d", i), i, time.Minute))
+		_, _ = c.Get(ctx, fmt.Sprintf("hot-%d", i%100))
+	}
+
+	hit := 0
+	for i := 0; i < 100; i++ {
+		if _, err := c.Get(ctx, fmt.Sprintf("hot-%d", i)); err == nil {
+			hit++
+		}
+	}
+	require.Equal(t, int32(100), c.cnt)
+	return hit
+}
+
+func TestWTinyLFUPolicy_Evict(t *testing.T) {
+	p := NewWTinyLFUPolicy(3)
+	// k1 被挤到 main
+	p.KeyAccessed("k1")
+	p.KeyAccessed("k1")
+	p.KeyAccessed("k2")
+	// window 里的 k2 只访问了一次，不如 main 里面的 k1 热
+	k, ok := p.Evict()
+	require.True(t, ok)
+	assert.Equal(t, "k2", k)
+
+	p.KeyAccessed("k3")
+	p.KeyAccessed("k3")
+	p.KeyAccessed("k3")
+	// k3 比 k1 热，k3 晋升，k1 被淘汰
+	k, ok = p.Evict()
+	require.True(t, ok)
+	assert.Equal(t, "k1", k)
+
+	k, ok = p.Evict()
+	require.True(t, ok)
+	assert.Equal(t, "k3", k)
+	_, ok = p.Evict()
+	assert.False(t, ok)
+}
+
+func TestCountMinSketch_Reset(t *testing.T) {
+	s := newCountMinSketch(16)
+	for i := 0; i < 10; i++ {
+		s.increment("k1")
+	}
+	assert.Equal(t, uint8(10), s.estimate("k1"))
+	s.reset()
+	// 第一次访问只记录在 doorkeeper 里面，计数器里面是 9，减半之后是 4
+	assert.Equal(t, uint8(4), s.estimate("k1"))
+	for i := 0; i < 100; i++ {
+		s.increment("k1")
+	}
+	assert.Equal(t, uint8(cmsMaxCount), s.estimate("k1"))
+}
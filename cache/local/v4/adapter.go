@@ -0,0 +1,47 @@
+package v4
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// CacheAdapter 把 LocalCache[string, V] 适配成 string/any 的 Cache 接口，
+// 这样可以和 redis 缓存以及其它版本的本地缓存互相替换
+type CacheAdapter[V any] struct {
+	c *LocalCache[string, V]
+}
+
+func NewCacheAdapter[V any](c *LocalCache[string, V]) *CacheAdapter[V] {
+	return &CacheAdapter[V]{
+		c: c,
+	}
+}
+
+func (a *CacheAdapter[V]) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	v, ok := val.(V)
+	if !ok {
+		return fmt.Errorf("%w, key: %s, type: %T", errInvalidType, key, val)
+	}
+	return a.c.Set(ctx, key, v, expiration)
+}
+
+func (a *CacheAdapter[V]) Get(ctx context.Context, key string) (any, error) {
+	v, err := a.c.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (a *CacheAdapter[V]) Delete(ctx context.Context, key string) error {
+	return a.c.Delete(ctx, key)
+}
+
+func (a *CacheAdapter[V]) LoadAndDelete(ctx context.Context, key string) (any, error) {
+	v, err := a.c.LoadAndDelete(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
@@ -0,0 +1,205 @@
+package v4
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/luxpo/time-go2nd/cache/internal/errs"
+	v3 "github.com/luxpo/time-go2nd/cache/local/v3"
+	"github.com/luxpo/time-go2nd/clock"
+)
+
+var (
+	// ErrKeyNotFound 和 redis 缓存返回的是同一个错误
+	ErrKeyNotFound = errs.ErrKeyNotFound
+	errInvalidType = errors.New("cache：invalid value type")
+)
+
+// EvictReason 和 v3 共用同一套移除原因
+type EvictReason = v3.EvictReason
+
+const (
+	EvictReasonExpired  = v3.EvictReasonExpired
+	EvictReasonCapacity = v3.EvictReasonCapacity
+	EvictReasonExplicit = v3.EvictReasonExplicit
+	EvictReasonReplaced = v3.EvictReasonReplaced
+)
+
+// EvictionListener 在 key 被移除之后调用，是在持有锁的时候调用的，不能在里面再操作缓存
+type EvictionListener[K comparable, V any] func(k K, v V, reason EvictReason)
+
+// LocalCache 是泛型版本的本地缓存，用户不再需要在 Get 之后做类型断言。
+// 过期清理还是每次最多扫描一部分 key，没有使用 v3 的时间轮
+type LocalCache[K comparable, V any] struct {
+	mu   sync.RWMutex
+	data map[K]*item[V]
+
+	closeOnce sync.Once
+	close     chan struct{}
+
+	onEvicted EvictionListener[K, V]
+	clock     clock.Clock
+}
+
+type LocalCacheOption[K comparable, V any] func(cache *LocalCache[K, V])
+
+type item[V any] struct {
+	val      V
+	deadline time.Time
+}
+
+func NewLocalCache[K comparable, V any](interval time.Duration, opts ...LocalCacheOption[K, V]) *LocalCache[K, V] {
+	c := &LocalCache[K, V]{
+		data:  make(map[K]*item[V]),
+		close: make(chan struct{}),
+		clock: clock.New(),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	go func() {
+		ticker := c.clock.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case t := <-ticker.C():
+				c.mu.Lock()
+				i := 0
+				for k, v := range c.data {
+					if i > 10000 {
+						break
+					}
+					if v.deadlineBeforeNow(t) {
+						c.delete(k, EvictReasonExpired)
+					}
+					i++
+				}
+				c.mu.Unlock()
+			case <-c.close:
+				return
+			}
+		}
+	}()
+
+	return c
+}
+
+// LocalCacheWithClock 一般只在测试里面使用
+func LocalCacheWithClock[K comparable, V any](clk clock.Clock) LocalCacheOption[K, V] {
+	return func(cache *LocalCache[K, V]) {
+		cache.clock = clk
+	}
+}
+
+// LocalCacheWithEvictedCallback 不关心移除原因的时候使用
+func LocalCacheWithEvictedCallback[K comparable, V any](fn func(k K, v V)) LocalCacheOption[K, V] {
+	return func(cache *LocalCache[K, V]) {
+		cache.onEvicted = func(k K, v V, reason EvictReason) {
+			fn(k, v)
+		}
+	}
+}
+
+// LocalCacheWithEvictionListener 过期、删除、覆盖都会通知 listener
+func LocalCacheWithEvictionListener[K comparable, V any](listener EvictionListener[K, V]) LocalCacheOption[K, V] {
+	return func(cache *LocalCache[K, V]) {
+		cache.onEvicted = listener
+	}
+}
+
+func (c *LocalCache[K, V]) Set(ctx context.Context, k K, v V, expiration time.Duration) error {
+	var dl time.Time
+	if expiration > 0 {
+		dl = c.clock.Now().Add(expiration)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old, ok := c.data[k]; ok {
+		c.notify(k, old.val, EvictReasonReplaced)
+	}
+	c.data[k] = &item[V]{
+		val:      v,
+		deadline: dl,
+	}
+
+	return nil
+}
+
+func (c *LocalCache[K, V]) Get(ctx context.Context, k K) (V, error) {
+	var zero V
+	c.mu.RLock()
+	i, ok := c.data[k]
+	c.mu.RUnlock()
+	if !ok {
+		return zero, fmt.Errorf("%w, key: %v", ErrKeyNotFound, k)
+	}
+
+	now := c.clock.Now()
+	if i.deadlineBeforeNow(now) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		// double check
+		i, ok = c.data[k]
+		if !ok {
+			return zero, fmt.Errorf("%w, key: %v", ErrKeyNotFound, k)
+		}
+		if i.deadlineBeforeNow(now) {
+			c.delete(k, EvictReasonExpired)
+			return zero, fmt.Errorf("%w, key: %v", ErrKeyNotFound, k)
+		}
+	}
+
+	return i.val, nil
+}
+
+func (c *LocalCache[K, V]) Delete(ctx context.Context, key K) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.delete(key, EvictReasonExplicit)
+	return nil
+}
+
+func (c *LocalCache[K, V]) LoadAndDelete(ctx context.Context, key K) (V, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.data[key]
+	if !ok {
+		var zero V
+		return zero, fmt.Errorf("%w, key: %v", ErrKeyNotFound, key)
+	}
+	c.delete(key, EvictReasonExplicit)
+	return v.val, nil
+}
+
+func (c *LocalCache[K, V]) Close() error {
+	c.closeOnce.Do(func() {
+		c.close <- struct{}{}
+	})
+	return nil
+}
+
+func (i *item[V]) deadlineBeforeNow(t time.Time) bool {
+	return !i.deadline.IsZero() && i.deadline.Before(t)
+}
+
+// delete 调用方需要持有写锁
+func (c *LocalCache[K, V]) delete(k K, reason EvictReason) {
+	item, ok := c.data[k]
+	if !ok {
+		return
+	}
+	delete(c.data, k)
+	c.notify(k, item.val, reason)
+}
+
+func (c *LocalCache[K, V]) notify(k K, v V, reason EvictReason) {
+	if c.onEvicted != nil {
+		c.onEvicted(k, v, reason)
+	}
+}
@@ -0,0 +1,98 @@
+package v4
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cache "github.com/luxpo/time-go2nd/cache/redis"
+	"github.com/luxpo/time-go2nd/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ cache.Cache = (*CacheAdapter[int])(nil)
+
+type user struct {
+	Name string
+}
+
+func TestLocalCache_Get(t *testing.T) {
+	var evicted []int64
+	clk := clock.NewFakeClock(time.Unix(0, 0))
+	c := NewLocalCache[int64, *user](time.Minute, LocalCacheWithEvictedCallback(func(k int64, v *user) {
+		evicted = append(evicted, k)
+	}), LocalCacheWithClock[int64, *user](clk))
+	defer c.Close()
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, 1, &user{Name: "Tom"}, time.Minute))
+	u, err := c.Get(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "Tom", u.Name)
+
+	_, err = c.Get(ctx, 2)
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+
+	require.NoError(t, c.Set(ctx, 3, &user{Name: "Jerry"}, time.Millisecond))
+	clk.Advance(time.Millisecond * 10)
+	_, err = c.Get(ctx, 3)
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	assert.Equal(t, []int64{3}, evicted)
+
+	u, err = c.LoadAndDelete(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "Tom", u.Name)
+	assert.Equal(t, []int64{3, 1}, evicted)
+}
+
+func TestLocalCache_EvictionListener(t *testing.T) {
+	type event struct {
+		key    string
+		val    int
+		reason EvictReason
+	}
+	var events []event
+	c := NewLocalCache[string, int](time.Minute, LocalCacheWithEvictionListener(func(k string, v int, reason EvictReason) {
+		events = append(events, event{key: k, val: v, reason: reason})
+	}))
+	defer c.Close()
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, "k1", 1, time.Minute))
+	require.NoError(t, c.Set(ctx, "k1", 2, time.Minute))
+	require.NoError(t, c.Delete(ctx, "k1"))
+	require.NoError(t, c.Set(ctx, "k2", 1, time.Minute))
+	_, err := c.LoadAndDelete(ctx, "k2")
+	require.NoError(t, err)
+	// 删除不存在的 key 不会通知
+	require.NoError(t, c.Delete(ctx, "k3"))
+
+	assert.Equal(t, []event{
+		{key: "k1", val: 1, reason: EvictReasonReplaced},
+		{key: "k1", val: 2, reason: EvictReasonExplicit},
+		{key: "k2", val: 1, reason: EvictReasonExplicit},
+	}, events)
+}
+
+func TestCacheAdapter(t *testing.T) {
+	lc := NewLocalCache[string, int](time.Minute)
+	defer lc.Close()
+	c := NewCacheAdapter(lc)
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, "k1", 1, time.Minute))
+	err := c.Set(ctx, "k2", "v2", time.Minute)
+	assert.ErrorIs(t, err, errInvalidType)
+
+	val, err := c.Get(ctx, "k1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, val)
+	_, err = c.Get(ctx, "k2")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+
+	val, err = c.LoadAndDelete(ctx, "k1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, val)
+	require.NoError(t, c.Delete(ctx, "k1"))
+}
@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/luxpo/time-go2nd/cache/internal/errs"
+	"github.com/redis/go-redis/v9"
+)
+
+var _ BatchCache = (*RedisCache)(nil)
+
+// MGet 用一次 MGET 读取所有的 key
+func (c *RedisCache) MGet(ctx context.Context, keys []string) (map[string]any, error) {
+	if len(keys) == 0 {
+		return map[string]any{}, nil
+	}
+	res, err := c.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	vals := make(map[string]any, len(keys))
+	var errList []error
+	for i, val := range res {
+		// 不存在的 key 返回的是 nil
+		if val == nil {
+			c.stats.RecordMiss()
+			errList = append(errList, errs.NewErrKeyNotFound(keys[i]))
+			continue
+		}
+		c.stats.RecordHit()
+		vals[keys[i]] = val
+	}
+	return vals, errors.Join(errList...)
+}
+
+// MSet 用 pipeline 发送 SET ... PX，MSET 不支持过期时间
+func (c *RedisCache) MSet(ctx context.Context, vals map[string]any, expiration time.Duration) error {
+	encoded := make(map[string]any, len(vals))
+	var errList []error
+	for k, v := range vals {
+		data, err := c.encode(v)
+		if err != nil {
+			errList = append(errList, fmt.Errorf("key: %s, %w", k, err))
+			continue
+		}
+		encoded[k] = data
+	}
+	cmds := make(map[string]*redis.StatusCmd, len(encoded))
+	// 每个命令的错误在下面单独处理
+	_, _ = c.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for k, v := range encoded {
+			cmds[k] = p.Set(ctx, k, v, expiration)
+		}
+		return nil
+	})
+	for k, cmd := range cmds {
+		res, err := cmd.Result()
+		if err == nil && res != "OK" {
+			err = fmt.Errorf("%w, 返回信息 %s", errFailedToSetCache, res)
+		}
+		if err != nil {
+			errList = append(errList, fmt.Errorf("key: %s, %w", k, err))
+			continue
+		}
+		c.stats.RecordSet()
+	}
+	return errors.Join(errList...)
+}
+
+// MDelete 用一次 DEL 删除所有的 key
+func (c *RedisCache) MDelete(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := c.client.Del(ctx, keys...).Result()
+	if err == nil {
+		for range keys {
+			c.stats.RecordDelete()
+		}
+	}
+	return err
+}
@@ -0,0 +1,199 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/luxpo/time-go2nd/cache/internal/errs"
+	v3 "github.com/luxpo/time-go2nd/cache/local/v3"
+	"github.com/luxpo/time-go2nd/cache/redis/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ BatchCache = (*v3.LocalCache)(nil)
+
+func TestRedisCache_MGet(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) redis.Cmdable
+
+		wantVals map[string]any
+		wantErr  error
+	}{
+		{
+			name: "all found",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				res := redis.NewSliceCmd(context.Background())
+				res.SetVal([]any{"v1", "v2"})
+				cmd.EXPECT().MGet(gomock.Any(), "k1", "k2").Return(res)
+				return cmd
+			},
+			wantVals: map[string]any{"k1": "v1", "k2": "v2"},
+		},
+		{
+			name: "partial missing",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				res := redis.NewSliceCmd(context.Background())
+				res.SetVal([]any{"v1", nil})
+				cmd.EXPECT().MGet(gomock.Any(), "k1", "k2").Return(res)
+				return cmd
+			},
+			wantVals: map[string]any{"k1": "v1"},
+			wantErr:  ErrKeyNotFound,
+		},
+		{
+			name: "redis error",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				res := redis.NewSliceCmd(context.Background())
+				res.SetErr(context.DeadlineExceeded)
+				cmd.EXPECT().MGet(gomock.Any(), "k1", "k2").Return(res)
+				return cmd
+			},
+			wantErr: context.DeadlineExceeded,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			vals, err := NewRedisCache(tc.mock(ctrl)).MGet(context.Background(), []string{"k1", "k2"})
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.wantVals, vals)
+		})
+	}
+}
+
+// setPipeliner 模拟 pipeline 里面的 SET，failed 里面的 key 会失败
+type setPipeliner struct {
+	redis.Pipeliner
+	vals   map[string]any
+	failed map[string]error
+}
+
+func (p *setPipeliner) Set(ctx context.Context, key string, value any, expiration time.Duration) *redis.StatusCmd {
+	cmd := redis.NewStatusCmd(ctx)
+	if err, ok := p.failed[key]; ok {
+		cmd.SetErr(err)
+		return cmd
+	}
+	p.vals[key] = value
+	cmd.SetVal("OK")
+	return cmd
+}
+
+func TestRedisCache_MSet(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	p := &setPipeliner{
+		vals:   map[string]any{},
+		failed: map[string]error{"k2": errors.New("mock error")},
+	}
+	cmd := mocks.NewMockCmdable(ctrl)
+	cmd.EXPECT().Pipelined(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, fn func(redis.Pipeliner) error) ([]redis.Cmder, error) {
+			return nil, fn(p)
+		})
+	c := NewRedisCache(cmd)
+
+	err := c.MSet(context.Background(), map[string]any{"k1": "v1", "k2": "v2", "k3": "v3"}, time.Minute)
+	assert.EqualError(t, err, "key: k2, mock error")
+	assert.Equal(t, map[string]any{"k1": "v1", "k3": "v3"}, p.vals)
+	assert.Equal(t, uint64(2), c.Stats().Sets)
+}
+
+func TestRedisCache_MDelete(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := mocks.NewMockCmdable(ctrl)
+	res := redis.NewIntCmd(context.Background())
+	res.SetVal(2)
+	cmd.EXPECT().Del(gomock.Any(), "k1", "k2").Return(res)
+
+	c := NewRedisCache(cmd)
+	require.NoError(t, c.MDelete(context.Background(), []string{"k1", "k2"}))
+	// 空的不会发请求
+	require.NoError(t, c.MDelete(context.Background(), nil))
+}
+
+func TestBatchReadThroughCache_MGet(t *testing.T) {
+	lc := v3.NewLocalCache(time.Minute)
+	defer func() {
+		_ = lc.Close()
+	}()
+	ctx := context.Background()
+	require.NoError(t, lc.Set(ctx, "k1", "v1", time.Minute))
+
+	var loaded [][]string
+	c := NewBatchReadThroughCache(lc, func(ctx context.Context, keys []string) (map[string]any, error) {
+		loaded = append(loaded, keys)
+		vals := make(map[string]any, len(keys))
+		for _, k := range keys {
+			if k != "k4" {
+				vals[k] = "loaded-" + k
+			}
+		}
+		return vals, nil
+	}, time.Minute)
+
+	vals, err := c.MGet(ctx, []string{"k1", "k2", "k3", "k4"})
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	assert.ErrorContains(t, err, "key: k4")
+	assert.Equal(t, map[string]any{"k1": "v1", "k2": "loaded-k2", "k3": "loaded-k3"}, vals)
+	// 只回源了缓存里面没有的 key
+	assert.Equal(t, [][]string{{"k2", "k3", "k4"}}, loaded)
+
+	// 回源的结果写回了缓存
+	vals, err = c.MGet(ctx, []string{"k1", "k2", "k3"})
+	require.NoError(t, err)
+	assert.Len(t, vals, 3)
+	assert.Len(t, loaded, 1)
+	assert.Equal(t, uint64(1), c.Stats().Loads)
+}
+
+func TestBatchReadThroughCache_MGetNegative(t *testing.T) {
+	lc := v3.NewLocalCache(time.Minute)
+	defer func() {
+		_ = lc.Close()
+	}()
+	ctx := context.Background()
+
+	// 单个读的时候写进去的负缓存
+	rc := NewReadThroughCache(lc, func(ctx context.Context, key string) (any, error) {
+		return nil, errs.NewErrKeyNotFound(key)
+	}, time.Minute, ReadThroughCacheWithNegativeCache(time.Minute))
+	_, err := rc.Get(ctx, "k2")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	require.NoError(t, lc.Set(ctx, "k1", "v1", time.Minute))
+
+	var loaded [][]string
+	c := NewBatchReadThroughCache(lc, func(ctx context.Context, keys []string) (map[string]any, error) {
+		loaded = append(loaded, keys)
+		vals := make(map[string]any, len(keys))
+		for _, k := range keys {
+			vals[k] = "loaded-" + k
+		}
+		return vals, nil
+	}, time.Minute)
+
+	// 负缓存的 key 当成不存在，也不回源
+	vals, err := c.MGet(ctx, []string{"k1", "k2", "k3"})
+	assert.ErrorIs(t, err, ErrNegativeCached)
+	assert.ErrorContains(t, err, "key: k2")
+	assert.Equal(t, map[string]any{"k1": "v1", "k3": "loaded-k3"}, vals)
+	assert.Equal(t, [][]string{{"k3"}}, loaded)
+
+	// 全部命中的时候也一样
+	vals, err = c.MGet(ctx, []string{"k1", "k2"})
+	assert.ErrorIs(t, err, ErrNegativeCached)
+	assert.Equal(t, map[string]any{"k1": "v1"}, vals)
+	assert.Len(t, loaded, 1)
+}
@@ -0,0 +1,259 @@
+package cache
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	_ BloomFilter = (*BitArrayBloomFilter)(nil)
+	_ BloomFilter = (*RedisBloomFilter)(nil)
+
+	errInvalidBloomParams = errors.New("cache: 布隆过滤器参数不合法")
+
+	//go:embed lua/bloom_add.lua
+	luaBloomAdd string
+	//go:embed lua/bloom_rebuild_start.lua
+	luaBloomRebuildStart string
+)
+
+// BloomFilter 判断一个 key 是否可能存在。
+// 返回 false 的时候 key 一定不存在，返回 true 的时候有一定的误判率
+type BloomFilter interface {
+	HasKey(ctx context.Context, key string) (bool, error)
+	Add(ctx context.Context, keys ...string) error
+	// Rebuild 用 keys 重新构建过滤器，数据源里面删除过数据之后需要定期重建。
+	// 重建期间 Add 的 key 在重建完成之后仍然存在
+	Rebuild(ctx context.Context, keys []string) error
+}
+
+// bloomParams 根据预计的元素数量和误判率计算位数组的长度 m 和哈希函数的个数 k。
+// expectedItems 必须大于 0，fpRate 必须在 (0, 1) 之间
+func bloomParams(expectedItems int, fpRate float64) (uint64, int, error) {
+	if expectedItems < 1 {
+		return 0, 0, fmt.Errorf("%w: expectedItems %d", errInvalidBloomParams, expectedItems)
+	}
+	// 取反是为了把 NaN 也拦下来
+	if !(fpRate > 0 && fpRate < 1) {
+		return 0, 0, fmt.Errorf("%w: fpRate %v", errInvalidBloomParams, fpRate)
+	}
+	n := float64(expectedItems)
+	m := math.Ceil(-n * math.Log(fpRate) / (math.Ln2 * math.Ln2))
+	k := int(math.Round(m / n * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return uint64(m), k, nil
+}
+
+// bloomLocations 用 double hashing 从一次哈希里面算出 k 个位置
+func bloomLocations(key string, m uint64, k int) []uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	sum := h.Sum64()
+	h1, h2 := sum&0xffffffff, (sum>>32)|1
+	locs := make([]uint64, k)
+	for i := range locs {
+		locs[i] = (h1 + uint64(i)*h2) % m
+	}
+	return locs
+}
+
+// BitArrayBloomFilter 进程内的布隆过滤器
+type BitArrayBloomFilter struct {
+	mu   sync.RWMutex
+	bits []uint64
+	m    uint64
+	k    int
+	// pending 是重建期间 Add 的 key，nil 表示没有在重建
+	pending []string
+
+	rebuildMu sync.Mutex
+}
+
+func NewBitArrayBloomFilter(expectedItems int, fpRate float64) (*BitArrayBloomFilter, error) {
+	m, k, err := bloomParams(expectedItems, fpRate)
+	if err != nil {
+		return nil, err
+	}
+	return &BitArrayBloomFilter{
+		bits: make([]uint64, (m+63)/64),
+		m:    m,
+		k:    k,
+	}, nil
+}
+
+func (b *BitArrayBloomFilter) HasKey(ctx context.Context, key string) (bool, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, loc := range bloomLocations(key, b.m, b.k) {
+		if b.bits[loc/64]&(1<<(loc%64)) == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (b *BitArrayBloomFilter) Add(ctx context.Context, keys ...string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	addBits(b.bits, b.m, b.k, keys)
+	if b.pending != nil {
+		b.pending = append(b.pending, keys...)
+	}
+	return nil
+}
+
+// Rebuild 在锁外面构建，构建完成之后直接替换。
+// 重建期间 Add 的 key 会被记下来，替换之前补到新的位数组里面
+func (b *BitArrayBloomFilter) Rebuild(ctx context.Context, keys []string) error {
+	b.rebuildMu.Lock()
+	defer b.rebuildMu.Unlock()
+	b.mu.Lock()
+	b.pending = []string{}
+	b.mu.Unlock()
+
+	bits := make([]uint64, len(b.bits))
+	addBits(bits, b.m, b.k, keys)
+	b.mu.Lock()
+	addBits(bits, b.m, b.k, b.pending)
+	b.bits = bits
+	b.pending = nil
+	b.mu.Unlock()
+	return nil
+}
+
+func addBits(bits []uint64, m uint64, k int, keys []string) {
+	for _, key := range keys {
+		for _, loc := range bloomLocations(key, m, k) {
+			bits[loc/64] |= 1 << (loc % 64)
+		}
+	}
+}
+
+// RedisBloomFilter 用 redis 的 bitmap 实现，多个实例共享同一个过滤器
+type RedisBloomFilter struct {
+	client redis.Cmdable
+	key    string
+	m      uint64
+	k      int
+	// batchSize 重建的时候每个 pipeline 最多包含多少个 key
+	batchSize int
+}
+
+func NewRedisBloomFilter(client redis.Cmdable, key string, expectedItems int, fpRate float64) (*RedisBloomFilter, error) {
+	m, k, err := bloomParams(expectedItems, fpRate)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisBloomFilter{
+		client:    client,
+		key:       key,
+		m:         m,
+		k:         k,
+		batchSize: 1000,
+	}, nil
+}
+
+func (r *RedisBloomFilter) HasKey(ctx context.Context, key string) (bool, error) {
+	locs := bloomLocations(key, r.m, r.k)
+	cmds := make([]*redis.IntCmd, len(locs))
+	_, err := r.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for i, loc := range locs {
+			cmds[i] = p.GetBit(ctx, r.key, int64(loc))
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	for _, cmd := range cmds {
+		if cmd.Val() == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Add 在重建期间会同时写到临时 key 里面，重建完成之后这些 key 也还在
+func (r *RedisBloomFilter) Add(ctx context.Context, keys ...string) error {
+	redisKeys := []string{r.key, r.rebuildingKey()}
+	for start := 0; start < len(keys); start += r.batchSize {
+		end := start + r.batchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		args := make([]any, 0, (end-start)*r.k)
+		for _, k := range keys[start:end] {
+			for _, loc := range bloomLocations(k, r.m, r.k) {
+				args = append(args, loc)
+			}
+		}
+		if err := r.client.Eval(ctx, luaBloomAdd, redisKeys, args...).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Rebuild 先写到临时的 key 里面，写完之后再 RENAME，重建期间查询不受影响。
+// 临时 key 和过滤器的 key 在同一个 slot 里面，集群模式下也能 RENAME
+func (r *RedisBloomFilter) Rebuild(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return r.client.Del(ctx, r.key).Err()
+	}
+	tmpKey := r.rebuildingKey()
+	err := r.client.Eval(ctx, luaBloomRebuildStart, []string{tmpKey}, r.m-1).Err()
+	if err != nil {
+		return err
+	}
+	if err = r.setBits(ctx, tmpKey, keys); err == nil {
+		err = r.client.Rename(ctx, tmpKey, r.key).Err()
+	}
+	if err != nil {
+		// 临时 key 还在的话 Add 会一直写两份
+		_ = r.client.Del(ctx, tmpKey).Err()
+	}
+	return err
+}
+
+// rebuildingKey 用 hash tag 保证临时 key 和过滤器的 key 在同一个 slot。
+// 没有 hash tag 的 key 按照整个 key 计算 slot，所以用整个 key 作为 hash tag；
+// 已经有 hash tag 的 key 直接加后缀就可以了
+func (r *RedisBloomFilter) rebuildingKey() string {
+	if start := strings.IndexByte(r.key, '{'); start >= 0 {
+		if end := strings.IndexByte(r.key[start+1:], '}'); end > 0 {
+			return r.key + ":rebuilding"
+		}
+	}
+	return "{" + r.key + "}:rebuilding"
+}
+
+func (r *RedisBloomFilter) setBits(ctx context.Context, key string, keys []string) error {
+	for start := 0; start < len(keys); start += r.batchSize {
+		end := start + r.batchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		_, err := r.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+			for _, k := range keys[start:end] {
+				for _, loc := range bloomLocations(k, r.m, r.k) {
+					p.SetBit(ctx, key, int64(loc), 1)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/luxpo/time-go2nd/cache/internal/errs"
+)
+
+var _ Cache = (*BloomFilterCache)(nil)
+
+// BloomFilterCache 防止缓存穿透：缓存未命中的时候先问布隆过滤器，
+// 过滤器认为不存在的 key 直接返回 ErrKeyNotFound，不会回源。
+// 过滤器里面需要提前放入数据源里面所有的 key。
+type BloomFilterCache struct {
+	*ReadThroughCache
+	filter BloomFilter
+}
+
+func NewBloomFilterCache(c Cache, filter BloomFilter, loadFunc LoadFunc, expiration time.Duration) *BloomFilterCache {
+	return &BloomFilterCache{
+		ReadThroughCache: NewReadThroughCache(c, loadFunc, expiration),
+		filter:           filter,
+	}
+}
+
+func (b *BloomFilterCache) Get(ctx context.Context, key string) (any, error) {
+	val, err := b.get(ctx, key)
+	if err == nil || !errors.Is(err, ErrKeyNotFound) {
+		return val, err
+	}
+	ok, err := b.filter.HasKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w, 布隆过滤器判定不存在", errs.NewErrKeyNotFound(key))
+	}
+	return b.load(ctx, key)
+}
+
+// Set 写入的 key 一定存在，顺便加到过滤器里面
+func (b *BloomFilterCache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	if err := b.Cache.Set(ctx, key, val, expiration); err != nil {
+		return err
+	}
+	return b.filter.Add(ctx, key)
+}
+
+// AddKeys 数据源新增数据之后调用
+func (b *BloomFilterCache) AddKeys(ctx context.Context, keys ...string) error {
+	return b.filter.Add(ctx, keys...)
+}
+
+// Rebuild 用数据源里面现有的 key 重建过滤器
+func (b *BloomFilterCache) Rebuild(ctx context.Context, keys []string) error {
+	return b.filter.Rebuild(ctx, keys)
+}
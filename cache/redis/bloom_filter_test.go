@@ -0,0 +1,250 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	v3 "github.com/luxpo/time-go2nd/cache/local/v3"
+	"github.com/luxpo/time-go2nd/cache/redis/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitArrayBloomFilter(t *testing.T) {
+	ctx := context.Background()
+	f, err := NewBitArrayBloomFilter(1000, 0.01)
+	require.NoError(t, err)
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("user-%d", i)
+	}
+	require.NoError(t, f.Add(ctx, keys...))
+	for _, k := range keys {
+		ok, err := f.HasKey(ctx, k)
+		require.NoError(t, err)
+		assert.True(t, ok)
+	}
+
+	fp := 0
+	for i := 0; i < 10000; i++ {
+		ok, _ := f.HasKey(ctx, fmt.Sprintf("attacker-%d", i))
+		if ok {
+			fp++
+		}
+	}
+	// 误判率 1%，留一点余量
+	assert.Less(t, fp, 300)
+
+	require.NoError(t, f.Rebuild(ctx, keys[:1]))
+	ok, _ := f.HasKey(ctx, keys[0])
+	assert.True(t, ok)
+}
+
+func TestBloomParams(t *testing.T) {
+	testCases := []struct {
+		name          string
+		expectedItems int
+		fpRate        float64
+		wantErr       error
+	}{
+		{name: "valid", expectedItems: 1000, fpRate: 0.01},
+		{name: "no items", expectedItems: 0, fpRate: 0.01, wantErr: errInvalidBloomParams},
+		{name: "zero fp rate", expectedItems: 1000, fpRate: 0, wantErr: errInvalidBloomParams},
+		{name: "negative fp rate", expectedItems: 1000, fpRate: -0.1, wantErr: errInvalidBloomParams},
+		{name: "fp rate 1", expectedItems: 1000, fpRate: 1, wantErr: errInvalidBloomParams},
+		{name: "NaN fp rate", expectedItems: 1000, fpRate: math.NaN(), wantErr: errInvalidBloomParams},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, k, err := bloomParams(tc.expectedItems, tc.fpRate)
+			assert.ErrorIs(t, err, tc.wantErr)
+			if err == nil {
+				assert.Equal(t, uint64(9586), m)
+				assert.Equal(t, 7, k)
+			}
+		})
+	}
+}
+
+func TestBitArrayBloomFilter_AddDuringRebuild(t *testing.T) {
+	ctx := context.Background()
+	f, err := NewBitArrayBloomFilter(1000, 0.01)
+	require.NoError(t, err)
+	keys := make([]string, 1000000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("user-%d", i)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- f.Rebuild(ctx, keys)
+	}()
+	// 等重建开始之后再 Add
+	for {
+		f.mu.RLock()
+		rebuilding := f.pending != nil
+		f.mu.RUnlock()
+		if rebuilding {
+			break
+		}
+		select {
+		case <-done:
+			t.Fatal("重建太快了，没有机会在重建期间 Add")
+		default:
+		}
+		runtime.Gosched()
+	}
+	require.NoError(t, f.Add(ctx, "late"))
+	require.NoError(t, <-done)
+
+	ok, err := f.HasKey(ctx, "late")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Nil(t, f.pending)
+}
+
+// fakePipeliner 用 map 模拟 bitmap，只实现了用到的方法
+type fakePipeliner struct {
+	redis.Pipeliner
+	bitmaps map[string]map[int64]bool
+}
+
+func (p *fakePipeliner) GetBit(ctx context.Context, key string, offset int64) *redis.IntCmd {
+	cmd := redis.NewIntCmd(ctx)
+	if p.bitmaps[key][offset] {
+		cmd.SetVal(1)
+	}
+	return cmd
+}
+
+func (p *fakePipeliner) SetBit(ctx context.Context, key string, offset int64, value int) *redis.IntCmd {
+	if p.bitmaps[key] == nil {
+		p.bitmaps[key] = make(map[int64]bool)
+	}
+	p.bitmaps[key][offset] = value == 1
+	return redis.NewIntCmd(ctx)
+}
+
+// evalBloomScript 在 fakePipeliner 上执行 bloom 相关的 lua 脚本
+func (p *fakePipeliner) evalBloomScript(ctx context.Context, script string, keys []string, args ...any) *redis.Cmd {
+	cmd := redis.NewCmd(ctx)
+	switch script {
+	case luaBloomRebuildStart:
+		p.bitmaps[keys[0]] = map[int64]bool{}
+	case luaBloomAdd:
+		_, rebuilding := p.bitmaps[keys[1]]
+		for _, arg := range args {
+			p.SetBit(ctx, keys[0], int64(arg.(uint64)), 1)
+			if rebuilding {
+				p.SetBit(ctx, keys[1], int64(arg.(uint64)), 1)
+			}
+		}
+	}
+	cmd.SetVal(int64(0))
+	return cmd
+}
+
+func TestRedisBloomFilter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	p := &fakePipeliner{bitmaps: map[string]map[int64]bool{}}
+	cmd := mocks.NewMockCmdable(ctrl)
+	cmd.EXPECT().Pipelined(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, fn func(redis.Pipeliner) error) ([]redis.Cmder, error) {
+			return nil, fn(p)
+		}).AnyTimes()
+	var f *RedisBloomFilter
+	cmd.EXPECT().Eval(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(p.evalBloomScript).AnyTimes()
+	cmd.EXPECT().Rename(gomock.Any(), "{bf}:rebuilding", "bf").
+		DoAndReturn(func(ctx context.Context, key, newKey string) *redis.StatusCmd {
+			// 重建期间的 Add 也写到了临时 key 里面
+			require.NoError(t, f.Add(ctx, "k4"))
+			p.bitmaps[newKey] = p.bitmaps[key]
+			delete(p.bitmaps, key)
+			return redis.NewStatusCmd(ctx)
+		})
+
+	f, err := NewRedisBloomFilter(cmd, "bf", 100, 0.01)
+	require.NoError(t, err)
+	require.NoError(t, f.Add(ctx, "k1", "k2"))
+	ok, err := f.HasKey(ctx, "k1")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = f.HasKey(ctx, "k3")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, f.Rebuild(ctx, []string{"k3"}))
+	ok, _ = f.HasKey(ctx, "k3")
+	assert.True(t, ok)
+	ok, _ = f.HasKey(ctx, "k1")
+	assert.False(t, ok)
+	ok, _ = f.HasKey(ctx, "k4")
+	assert.True(t, ok)
+}
+
+func TestRedisBloomFilter_RebuildingKey(t *testing.T) {
+	testCases := []struct {
+		key  string
+		want string
+	}{
+		{key: "bf", want: "{bf}:rebuilding"},
+		{key: "{user}:bf", want: "{user}:bf:rebuilding"},
+		// 空的 hash tag 不算，整个 key 参与计算 slot
+		{key: "{}:bf", want: "{{}:bf}:rebuilding"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.key, func(t *testing.T) {
+			f, err := NewRedisBloomFilter(nil, tc.key, 100, 0.01)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, f.rebuildingKey())
+		})
+	}
+}
+
+func TestBloomFilterCache_Get(t *testing.T) {
+	ctx := context.Background()
+	lc := v3.NewLocalCache(time.Minute)
+	defer lc.Close()
+	var loads atomic.Int32
+	f, err := NewBitArrayBloomFilter(100, 0.01)
+	require.NoError(t, err)
+	c := NewBloomFilterCache(lc, f,
+		func(ctx context.Context, key string) (any, error) {
+			loads.Add(1)
+			return "v-" + key, nil
+		}, time.Minute)
+	require.NoError(t, c.AddKeys(ctx, "k1"))
+
+	// 不存在的 key 不会回源
+	_, err = c.Get(ctx, "k2")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	assert.Equal(t, int32(0), loads.Load())
+
+	val, err := c.Get(ctx, "k1")
+	require.NoError(t, err)
+	assert.Equal(t, "v-k1", val)
+	assert.Equal(t, int32(1), loads.Load())
+
+	// Set 之后 key 会被加入过滤器
+	require.NoError(t, c.Set(ctx, "k3", "v3", time.Minute))
+	require.NoError(t, lc.Delete(ctx, "k3"))
+	val, err = c.Get(ctx, "k3")
+	require.NoError(t, err)
+	assert.Equal(t, "v-k3", val)
+
+	require.NoError(t, c.Rebuild(ctx, nil))
+	require.NoError(t, lc.Delete(ctx, "k1"))
+	_, err = c.Get(ctx, "k1")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+}
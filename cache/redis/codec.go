@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"context"
+	"encoding"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/luxpo/time-go2nd/micro/rpc2/serialize"
+)
+
+var errUnsupportedDst = errors.New("cache: 没有设置 serializer，只能读到 *string 或者 *[]byte 里面")
+
+// RedisCacheWithSerializer 写入之前用 s 编码，GetInto 的时候用 s 解码，
+// 比如 micro/rpc2/serialize 里面的 json 和 proto。
+// Get 依旧返回 redis 里面的原始数据，需要用 GetInto 或者 TypedCache 拿到原来的类型。
+// ReadThroughCache 的负缓存标记和 JitterCache 的软过期不经过 s，依旧按照自己的格式写入，
+// 所以开启软过期的时候值只能是 string 或者 []byte
+func RedisCacheWithSerializer(s serialize.Serializer) RedisCacheOption {
+	return func(c *RedisCache) {
+		c.serializer = s
+	}
+}
+
+// selfEncoded 只有缓存内部的标记实现，用户的值即使实现了 encoding.BinaryMarshaler，
+// 比如 time.Time，也要经过 serializer
+type selfEncoded interface {
+	encoding.BinaryMarshaler
+	selfEncoded()
+}
+
+func (c *RedisCache) encode(val any) (any, error) {
+	if c.serializer == nil {
+		return val, nil
+	}
+	// 这些值有自己在 redis 里面的格式，读的时候也是按照这个格式识别的
+	if _, ok := val.(selfEncoded); ok {
+		return val, nil
+	}
+	data, err := c.serializer.Encode(val)
+	if err != nil {
+		return nil, fmt.Errorf("cache: 编码失败 %w", err)
+	}
+	return data, nil
+}
+
+// GetInto 读取 key 并且解码到 dst 里面，dst 必须是指针
+func (c *RedisCache) GetInto(ctx context.Context, key string, dst any) error {
+	val, err := c.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	// 从 redis 里面读出来的一定是 string
+	data := val.(string)
+	if c.serializer != nil {
+		if err = c.serializer.Decode([]byte(data), dst); err != nil {
+			return fmt.Errorf("cache: 解码失败, key: %s, %w", key, err)
+		}
+		return nil
+	}
+	switch d := dst.(type) {
+	case *string:
+		*d = data
+	case *[]byte:
+		*d = []byte(data)
+	default:
+		return fmt.Errorf("%w, type: %T", errUnsupportedDst, dst)
+	}
+	return nil
+}
+
+// TypedCache 在 RedisCache 的基础上提供类型安全的读写，
+// RedisCache 一般需要设置 serializer，否则 T 只能是 string 或者 []byte
+type TypedCache[T any] struct {
+	c *RedisCache
+}
+
+func NewTypedCache[T any](c *RedisCache) *TypedCache[T] {
+	return &TypedCache[T]{
+		c: c,
+	}
+}
+
+func (t *TypedCache[T]) Set(ctx context.Context, key string, val T, expiration time.Duration) error {
+	return t.c.Set(ctx, key, val, expiration)
+}
+
+func (t *TypedCache[T]) Get(ctx context.Context, key string) (T, error) {
+	var val T
+	err := t.c.GetInto(ctx, key, &val)
+	return val, err
+}
+
+func (t *TypedCache[T]) Delete(ctx context.Context, key string) error {
+	return t.c.Delete(ctx, key)
+}
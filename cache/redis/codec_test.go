@@ -0,0 +1,177 @@
+package cache
+
+import (
+	"context"
+	"encoding"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/luxpo/time-go2nd/cache/redis/mocks"
+	"github.com/luxpo/time-go2nd/clock"
+	"github.com/luxpo/time-go2nd/micro/rpc2/serialize/json"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type codecUser struct {
+	Name string
+	Age  int
+}
+
+// storeCmdable 用 map 模拟 redis 的 SET 和 GET，值和 go-redis 一样按照字符串保存
+func storeCmdable(ctrl *gomock.Controller) redis.Cmdable {
+	data := make(map[string]string)
+	cmd := mocks.NewMockCmdable(ctrl)
+	cmd.EXPECT().Set(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, key string, val any, expiration time.Duration) *redis.StatusCmd {
+			switch v := val.(type) {
+			case []byte:
+				data[key] = string(v)
+			case string:
+				data[key] = v
+			case encoding.BinaryMarshaler:
+				// 和 go-redis 一样调用 MarshalBinary
+				bs, err := v.MarshalBinary()
+				if err != nil {
+					res := redis.NewStatusCmd(ctx)
+					res.SetErr(err)
+					return res
+				}
+				data[key] = string(bs)
+			}
+			res := redis.NewStatusCmd(ctx)
+			res.SetVal("OK")
+			return res
+		}).AnyTimes()
+	cmd.EXPECT().Get(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, key string) *redis.StringCmd {
+			res := redis.NewStringCmd(ctx)
+			val, ok := data[key]
+			if !ok {
+				res.SetErr(redis.Nil)
+				return res
+			}
+			res.SetVal(val)
+			return res
+		}).AnyTimes()
+	return cmd
+}
+
+func TestRedisCache_GetInto(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	c := NewRedisCache(storeCmdable(ctrl), RedisCacheWithSerializer(&json.Serializer{}))
+	require.NoError(t, c.Set(ctx, "u1", codecUser{Name: "Tom", Age: 18}, time.Minute))
+	var u codecUser
+	require.NoError(t, c.GetInto(ctx, "u1", &u))
+	assert.Equal(t, codecUser{Name: "Tom", Age: 18}, u)
+	// Get 返回的是编码之后的数据
+	val, err := c.Get(ctx, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, `{"Name":"Tom","Age":18}`, val)
+
+	err = c.GetInto(ctx, "u2", &u)
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestRedisCache_GetIntoWithoutSerializer(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	c := NewRedisCache(storeCmdable(ctrl))
+	require.NoError(t, c.Set(ctx, "k1", "v1", time.Minute))
+	var s string
+	require.NoError(t, c.GetInto(ctx, "k1", &s))
+	assert.Equal(t, "v1", s)
+	var bs []byte
+	require.NoError(t, c.GetInto(ctx, "k1", &bs))
+	assert.Equal(t, []byte("v1"), bs)
+
+	var u codecUser
+	assert.ErrorIs(t, c.GetInto(ctx, "k1", &u), errUnsupportedDst)
+}
+
+func TestTypedCache(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	c := NewTypedCache[*codecUser](NewRedisCache(storeCmdable(ctrl), RedisCacheWithSerializer(&json.Serializer{})))
+	require.NoError(t, c.Set(ctx, "u1", &codecUser{Name: "Jerry", Age: 3}, time.Minute))
+	u, err := c.Get(ctx, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, &codecUser{Name: "Jerry", Age: 3}, u)
+
+	ints := NewTypedCache[[]int](NewRedisCache(storeCmdable(ctrl), RedisCacheWithSerializer(&json.Serializer{})))
+	require.NoError(t, ints.Set(ctx, "ints", []int{1, 2, 3}, time.Minute))
+	vals, err := ints.Get(ctx, "ints")
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3}, vals)
+}
+
+func TestTypedCache_BinaryMarshaler(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	// time.Time 实现了 encoding.BinaryMarshaler，依旧要用 json 编码
+	c := NewTypedCache[time.Time](NewRedisCache(storeCmdable(ctrl), RedisCacheWithSerializer(&json.Serializer{})))
+	now := time.Date(2023, 1, 2, 3, 4, 5, 6, time.UTC)
+	require.NoError(t, c.Set(ctx, "t1", now, time.Minute))
+	val, err := c.Get(ctx, "t1")
+	require.NoError(t, err)
+	assert.True(t, now.Equal(val))
+}
+
+func TestRedisCache_SerializerWithMarkers(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("negative cache", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		var loads int
+		c := NewReadThroughCache(
+			NewRedisCache(storeCmdable(ctrl), RedisCacheWithSerializer(&json.Serializer{})),
+			func(ctx context.Context, key string) (any, error) {
+				loads++
+				return nil, ErrKeyNotFound
+			}, time.Minute, ReadThroughCacheWithNegativeCache(time.Minute))
+
+		_, err := c.Get(ctx, "k1")
+		assert.ErrorIs(t, err, ErrKeyNotFound)
+		// 负缓存的标记不能被 json 编码，否则读出来就认不出来了
+		_, err = c.Get(ctx, "k1")
+		assert.ErrorIs(t, err, ErrNegativeCached)
+		assert.Equal(t, 1, loads)
+	})
+
+	t.Run("soft ttl", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		clk := clock.NewFakeClock(time.Unix(100, 0))
+		c := NewJitterCache(
+			NewRedisCache(storeCmdable(ctrl), RedisCacheWithSerializer(&json.Serializer{})),
+			JitterCacheWithSoftTTL(0.5), JitterCacheWithClock(clk))
+
+		require.NoError(t, c.Set(ctx, "k1", "v1", time.Minute))
+		val, stale, err := c.GetWithStale(ctx, "k1")
+		require.NoError(t, err)
+		assert.Equal(t, "v1", val)
+		assert.False(t, stale)
+
+		clk.Advance(time.Minute)
+		val, stale, err = c.GetWithStale(ctx, "k1")
+		require.NoError(t, err)
+		assert.Equal(t, "v1", val)
+		assert.True(t, stale)
+
+		// 软过期的值不经过 serializer，只能是 string 或者 []byte
+		err = c.Set(ctx, "k2", codecUser{Name: "Tom"}, time.Minute)
+		assert.ErrorIs(t, err, errUnsupportedSoftTTLValue)
+	})
+}
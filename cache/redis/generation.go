@@ -0,0 +1,41 @@
+package cache
+
+import "sync/atomic"
+
+const generationSlots = 1024
+
+// generations 记录 key 被失效了多少次，用来发现回填本地缓存期间发生了失效。
+// 按照 key 的哈希分成固定数量的槽，内存不会随着 key 的数量增长；
+// 同一个槽里面别的 key 失效只会让回填多放弃一次，不影响正确性。
+//
+// 回填的一方先 load，回填之后再 load 一次，不一样的话删掉刚刚回填的值；
+// 失效的一方先 bump 再删除本地缓存。这样不管两边怎么交错，旧值都不会留在本地缓存里面
+type generations struct {
+	slots [generationSlots]atomic.Uint64
+}
+
+func (g *generations) load(key string) uint64 {
+	return g.slots[generationSlot(key)].Load()
+}
+
+func (g *generations) bump(key string) {
+	g.slots[generationSlot(key)].Add(1)
+}
+
+func generationSlot(key string) uint64 {
+	return fingerprint(key) % generationSlots
+}
+
+// fingerprint 使用 FNV-1a，手写是为了避免 hash/fnv 的内存分配
+func fingerprint(key string) uint64 {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+	h := uint64(offset64)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= prime64
+	}
+	return h
+}
@@ -0,0 +1,172 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+var _ Cache = (*HotKeyCache)(nil)
+
+// HotKeyCache 放在 RedisCache 前面，发现热点 key 之后自动放到本地缓存（一般是 v3.LocalCache），
+// 之后对这个 key 的读取不再访问 redis，避免打爆单个分片。
+// 每个窗口结束的时候，已经冷下来的 key 会从本地缓存里面删掉。
+// 写操作会删掉自己的本地副本，但是不会通知别的实例，
+// 所以本地缓存的过期时间要设置得比较短，它就是别的实例读到旧数据的最长时间
+type HotKeyCache struct {
+	Cache
+	local    Cache
+	detector *HotKeyDetector
+
+	window          time.Duration
+	localExpiration time.Duration
+
+	mu sync.Mutex
+	// promoted 已经放到本地缓存里面的 key
+	promoted map[string]struct{}
+	// gens 用来发现放到本地缓存期间发生的写操作
+	gens generations
+
+	closeOnce sync.Once
+	close     chan struct{}
+}
+
+type HotKeyCacheOption func(c *HotKeyCache)
+
+// NewHotKeyCache 默认最多 100 个热点，一秒内访问 1000 次算热点，本地缓存一秒过期
+func NewHotKeyCache(c Cache, local Cache, opts ...HotKeyCacheOption) *HotKeyCache {
+	hc := &HotKeyCache{
+		Cache:           c,
+		local:           local,
+		detector:        NewHotKeyDetector(100, 1000),
+		window:          time.Second,
+		localExpiration: time.Second,
+		promoted:        make(map[string]struct{}),
+		close:           make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(hc)
+	}
+	go hc.loop()
+	return hc
+}
+
+// HotKeyCacheWithDetector k 是最多多少个热点，threshold 是一个窗口内至少访问多少次才算热点
+func HotKeyCacheWithDetector(k int, threshold uint32) HotKeyCacheOption {
+	return func(c *HotKeyCache) {
+		c.detector = NewHotKeyDetector(k, threshold)
+	}
+}
+
+// HotKeyCacheWithWindow 每隔 window 衰减一次计数，检查热点有没有冷下来
+func HotKeyCacheWithWindow(window time.Duration) HotKeyCacheOption {
+	return func(c *HotKeyCache) {
+		c.window = window
+	}
+}
+
+func HotKeyCacheWithLocalExpiration(expiration time.Duration) HotKeyCacheOption {
+	return func(c *HotKeyCache) {
+		c.localExpiration = expiration
+	}
+}
+
+func (h *HotKeyCache) Get(ctx context.Context, key string) (any, error) {
+	hot := h.detector.Record(key)
+	if hot {
+		if val, err := h.local.Get(ctx, key); err == nil {
+			return val, nil
+		}
+	}
+	gen := h.gens.load(key)
+	val, err := h.Cache.Get(ctx, key)
+	if err != nil || !hot {
+		return val, err
+	}
+	// 本地缓存过期了也会走到这里，重新放回去
+	if h.local.Set(ctx, key, val, h.localExpiration) == nil {
+		h.mu.Lock()
+		h.promoted[key] = struct{}{}
+		h.mu.Unlock()
+		// 读 redis 期间 key 被修改了，放进去的可能是旧值
+		if h.gens.load(key) != gen {
+			h.demote(ctx, key)
+		}
+	}
+	return val, nil
+}
+
+func (h *HotKeyCache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	err := h.Cache.Set(ctx, key, val, expiration)
+	h.demote(ctx, key)
+	return err
+}
+
+func (h *HotKeyCache) Delete(ctx context.Context, key string) error {
+	err := h.Cache.Delete(ctx, key)
+	h.demote(ctx, key)
+	return err
+}
+
+func (h *HotKeyCache) LoadAndDelete(ctx context.Context, key string) (any, error) {
+	val, err := h.Cache.LoadAndDelete(ctx, key)
+	h.demote(ctx, key)
+	return val, err
+}
+
+// HotKeys 返回当前的热点，按照访问次数从大到小排序，可以用来做监控
+func (h *HotKeyCache) HotKeys() []HotKey {
+	return h.detector.HotKeys()
+}
+
+// Close 停止后台的衰减，不会关闭 local
+func (h *HotKeyCache) Close() error {
+	h.closeOnce.Do(func() {
+		close(h.close)
+	})
+	return nil
+}
+
+// demote 要先更新 generation 再删除，参考 generations
+func (h *HotKeyCache) demote(ctx context.Context, key string) {
+	h.gens.bump(key)
+	h.mu.Lock()
+	_, ok := h.promoted[key]
+	delete(h.promoted, key)
+	h.mu.Unlock()
+	if ok {
+		_ = h.local.Delete(ctx, key)
+	}
+}
+
+func (h *HotKeyCache) loop() {
+	ticker := time.NewTicker(h.window)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			h.rotate()
+		case <-h.close:
+			return
+		}
+	}
+}
+
+// rotate 衰减计数，把已经不是热点的 key 从本地缓存里面删掉。
+// 是不是热点按照减半之前的计数判断，参考 HotKeyDetector.Decay
+func (h *HotKeyCache) rotate() {
+	h.detector.Decay()
+	var cooled []string
+	h.mu.Lock()
+	for k := range h.promoted {
+		if !h.detector.IsHot(k) {
+			cooled = append(cooled, k)
+			delete(h.promoted, k)
+		}
+	}
+	h.mu.Unlock()
+	ctx := context.Background()
+	for _, k := range cooled {
+		_ = h.local.Delete(ctx, k)
+	}
+}
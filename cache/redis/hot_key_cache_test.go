@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	v3 "github.com/luxpo/time-go2nd/cache/local/v3"
+	"github.com/luxpo/time-go2nd/cache/redis/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHotKeyCache_Get(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := mocks.NewMockCmdable(ctrl)
+	str := redis.NewStringCmd(context.Background())
+	str.SetVal("v1")
+	// 前 10 次访问 redis，第 10 次变成热点并放到本地缓存，
+	// 冷下来之后第 11 次又访问 redis
+	cmd.EXPECT().Get(gomock.Any(), "hot").Return(str).Times(11)
+
+	lc := v3.NewLocalCache(time.Minute)
+	defer func() {
+		_ = lc.Close()
+	}()
+	c := NewHotKeyCache(NewRedisCache(cmd), lc,
+		HotKeyCacheWithDetector(10, 10),
+		HotKeyCacheWithWindow(time.Hour),
+		HotKeyCacheWithLocalExpiration(time.Minute))
+	defer func() {
+		_ = c.Close()
+	}()
+	ctx := context.Background()
+
+	for i := 0; i < 100; i++ {
+		val, err := c.Get(ctx, "hot")
+		require.NoError(t, err)
+		assert.Equal(t, "v1", val)
+	}
+	assert.Equal(t, []HotKey{{Key: "hot", Count: 100}}, c.HotKeys())
+	_, err := lc.Get(ctx, "hot")
+	require.NoError(t, err)
+
+	// 100 -> 50 -> 25 -> 12 -> 6，窗口结束的时候低于阈值才从本地缓存里面删掉
+	for i := 0; i < 4; i++ {
+		c.rotate()
+	}
+	assert.Len(t, c.HotKeys(), 1)
+	c.rotate()
+	assert.Empty(t, c.HotKeys())
+	_, err = lc.Get(ctx, "hot")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	val, err := c.Get(ctx, "hot")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", val)
+}
+
+func TestHotKeyCache_SteadyRate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := mocks.NewMockCmdable(ctrl)
+	str := redis.NewStringCmd(context.Background())
+	str.SetVal("v1")
+	// 第一个窗口 7 次，减半之后第二个窗口 3 + 7 次正好变成热点，
+	// 之后每个窗口 7 次都在 threshold/2 和 threshold 之间，一直从本地缓存读
+	cmd.EXPECT().Get(gomock.Any(), "steady").Return(str).Times(14)
+
+	lc := v3.NewLocalCache(time.Minute)
+	defer func() {
+		_ = lc.Close()
+	}()
+	c := NewHotKeyCache(NewRedisCache(cmd), lc,
+		HotKeyCacheWithDetector(10, 10),
+		HotKeyCacheWithWindow(time.Hour),
+		HotKeyCacheWithLocalExpiration(time.Minute))
+	defer func() {
+		_ = c.Close()
+	}()
+	ctx := context.Background()
+
+	for w := 0; w < 10; w++ {
+		for i := 0; i < 7; i++ {
+			val, err := c.Get(ctx, "steady")
+			require.NoError(t, err)
+			assert.Equal(t, "v1", val)
+		}
+		c.rotate()
+	}
+	assert.Len(t, c.HotKeys(), 1)
+}
+
+func TestHotKeyCache_Set(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := mocks.NewMockCmdable(ctrl)
+	str := redis.NewStringCmd(context.Background())
+	str.SetVal("v1")
+	cmd.EXPECT().Get(gomock.Any(), "hot").Return(str).Times(2)
+	status := redis.NewStatusCmd(context.Background())
+	status.SetVal("OK")
+	cmd.EXPECT().Set(gomock.Any(), "hot", "v2", time.Minute).Return(status)
+	newStr := redis.NewStringCmd(context.Background())
+	newStr.SetVal("v2")
+	cmd.EXPECT().Get(gomock.Any(), "hot").Return(newStr)
+
+	lc := v3.NewLocalCache(time.Minute)
+	defer func() {
+		_ = lc.Close()
+	}()
+	c := NewHotKeyCache(NewRedisCache(cmd), lc,
+		HotKeyCacheWithDetector(10, 2),
+		HotKeyCacheWithWindow(time.Hour))
+	defer func() {
+		_ = c.Close()
+	}()
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		_, err := c.Get(ctx, "hot")
+		require.NoError(t, err)
+	}
+	// 写的时候删掉本地副本，下一次读到新的值
+	require.NoError(t, c.Set(ctx, "hot", "v2", time.Minute))
+	val, err := c.Get(ctx, "hot")
+	require.NoError(t, err)
+	assert.Equal(t, "v2", val)
+}
+
+func TestHotKeyCache_GetModifiedDuringLoad(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := mocks.NewMockCmdable(ctrl)
+	lc := v3.NewLocalCache(time.Minute)
+	defer func() {
+		_ = lc.Close()
+	}()
+	c := NewHotKeyCache(NewRedisCache(cmd), lc,
+		HotKeyCacheWithDetector(10, 1),
+		HotKeyCacheWithWindow(time.Hour))
+	defer func() {
+		_ = c.Close()
+	}()
+	ctx := context.Background()
+
+	status := redis.NewStatusCmd(ctx)
+	status.SetVal("OK")
+	cmd.EXPECT().Set(gomock.Any(), "hot", "new", time.Minute).Return(status)
+	cmd.EXPECT().Get(gomock.Any(), "hot").
+		DoAndReturn(func(ctx context.Context, key string) *redis.StringCmd {
+			// 读到旧值之后，放到本地缓存之前，key 被修改了
+			require.NoError(t, c.Set(ctx, "hot", "new", time.Minute))
+			str := redis.NewStringCmd(ctx)
+			str.SetVal("old")
+			return str
+		})
+	val, err := c.Get(ctx, "hot")
+	require.NoError(t, err)
+	assert.Equal(t, "old", val)
+	// 旧值不能留在本地缓存里面
+	_, err = lc.Get(ctx, "hot")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	assert.Empty(t, c.promoted)
+}
@@ -0,0 +1,256 @@
+package cache
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	heavyKeeperDepth = 4
+	// heavyKeeperDecay 计数越大，被别的 key 挤掉的概率越小
+	heavyKeeperDecay = 1.08
+)
+
+// decayProbs 预先算好 decay^-count，避免每次都调用 math.Pow。
+// 计数超过 255 之后概率小于十亿分之三，直接当作 0
+var decayProbs = func() [256]float64 {
+	var probs [256]float64
+	for i := range probs {
+		probs[i] = math.Pow(heavyKeeperDecay, -float64(i))
+	}
+	return probs
+}()
+
+// HotKey 热点 key 和它在当前窗口里面的估算访问次数
+type HotKey struct {
+	Key   string
+	Count uint32
+}
+
+// HotKeyDetector 用 HeavyKeeper 估算访问次数，配合一个大小为 k 的最小堆维护 top-K。
+// 每个窗口结束的时候调用 Decay 把计数减半，很久以前的访问慢慢就不算数了，
+// 近似于一个滑动窗口。
+// top-K 里面计数达到 threshold 的 key 变成热点，之后一直是热点，
+// 直到某个窗口结束的时候（减半之前）计数低于 threshold。
+// 这样稳定地每个窗口访问 threshold/2 到 threshold 次的 key 不会在每次减半之后反复进出
+type HotKeyDetector struct {
+	mu        sync.Mutex
+	sketch    *heavyKeeper
+	top       *topK
+	threshold uint32
+	// rnd 受 mu 保护，全局的 rand 有自己的锁
+	rnd *rand.Rand
+}
+
+// NewHotKeyDetector k 是最多多少个热点，threshold 是一个窗口内至少访问多少次才算热点
+func NewHotKeyDetector(k int, threshold uint32) *HotKeyDetector {
+	width := k * 16
+	if width < 1024 {
+		width = 1024
+	}
+	return &HotKeyDetector{
+		sketch:    newHeavyKeeper(width),
+		top:       newTopK(k),
+		threshold: threshold,
+		rnd:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Record 记录一次访问，返回这个 key 现在是不是热点
+func (d *HotKeyDetector) Record(key string) bool {
+	// 哈希放在锁外面
+	fp := fingerprint(key)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	cnt := d.sketch.add(fp, d.rnd)
+	e := d.top.update(key, cnt)
+	if e == nil {
+		return false
+	}
+	if cnt >= d.threshold {
+		e.hot = true
+	}
+	return e.hot
+}
+
+// IsHot 只判断，不记录访问
+func (d *HotKeyDetector) IsHot(key string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e := d.top.get(key)
+	return e != nil && e.hot
+}
+
+// Decay 计数减半，一般每个窗口调用一次。
+// 减半之前计数已经低于 threshold 的 key 不再是热点
+func (d *HotKeyDetector) Decay() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, e := range d.top.entries {
+		e.hot = e.Count >= d.threshold
+	}
+	d.sketch.halve()
+	d.top.halve()
+}
+
+// HotKeys 返回当前所有的热点，按照访问次数从大到小排序
+func (d *HotKeyDetector) HotKeys() []HotKey {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	res := make([]HotKey, 0, len(d.top.entries))
+	for _, e := range d.top.entries {
+		if e.hot {
+			res = append(res, e.HotKey)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Count > res[j].Count
+	})
+	return res
+}
+
+// heavyKeeper 每个桶保存一个指纹和计数。
+// 指纹不一样的时候按照 decay^-count 的概率把计数减一，减到 0 之后被新的 key 占用，
+// 这样大流量的 key 会留下来，小流量的 key 很快被挤掉
+type heavyKeeper struct {
+	rows  [heavyKeeperDepth][]hkBucket
+	width uint64
+}
+
+type hkBucket struct {
+	fp    uint64
+	count uint32
+}
+
+func newHeavyKeeper(width int) *heavyKeeper {
+	hk := &heavyKeeper{width: uint64(width)}
+	for i := range hk.rows {
+		hk.rows[i] = make([]hkBucket, width)
+	}
+	return hk
+}
+
+// add 返回指纹为 fp 的 key 的估算访问次数
+func (hk *heavyKeeper) add(fp uint64, rnd *rand.Rand) uint32 {
+	// double hashing，和 countMinSketch 一样
+	h1, h2 := fp&0xffffffff, (fp>>32)|1
+
+	var res uint32
+	for i := range hk.rows {
+		b := &hk.rows[i][(h1+uint64(i)*h2)%hk.width]
+		switch {
+		case b.count == 0:
+			b.fp, b.count = fp, 1
+		case b.fp == fp:
+			b.count++
+		case b.count < uint32(len(decayProbs)) && rnd.Float64() < decayProbs[b.count]:
+			b.count--
+			if b.count == 0 {
+				b.fp, b.count = fp, 1
+			}
+		}
+		if b.fp == fp && b.count > res {
+			res = b.count
+		}
+	}
+	return res
+}
+
+func (hk *heavyKeeper) halve() {
+	for i := range hk.rows {
+		for j := range hk.rows[i] {
+			hk.rows[i][j].count >>= 1
+		}
+	}
+}
+
+// topK 是一个按照计数排序的最小堆，堆顶是 top-K 里面最冷的 key
+type topK struct {
+	k       int
+	entries []*topEntry
+	index   map[string]int
+}
+
+type topEntry struct {
+	HotKey
+	// hot 是不是热点，参考 HotKeyDetector
+	hot bool
+}
+
+func newTopK(k int) *topK {
+	return &topK{
+		k:     k,
+		index: make(map[string]int, k),
+	}
+}
+
+// update 返回 key 在 top-K 里面的记录，不在 top-K 里面的时候返回 nil
+func (t *topK) update(key string, cnt uint32) *topEntry {
+	if i, ok := t.index[key]; ok {
+		e := t.entries[i]
+		e.Count = cnt
+		heap.Fix(t, i)
+		return e
+	}
+	e := &topEntry{HotKey: HotKey{Key: key, Count: cnt}}
+	if len(t.entries) < t.k {
+		heap.Push(t, e)
+		return e
+	}
+	if t.k > 0 && cnt > t.entries[0].Count {
+		delete(t.index, t.entries[0].Key)
+		t.entries[0] = e
+		t.index[key] = 0
+		heap.Fix(t, 0)
+		return e
+	}
+	return nil
+}
+
+func (t *topK) get(key string) *topEntry {
+	i, ok := t.index[key]
+	if !ok {
+		return nil
+	}
+	return t.entries[i]
+}
+
+// halve 所有的计数同时减半不会破坏堆的顺序，减到 0 的直接移除
+func (t *topK) halve() {
+	for _, e := range t.entries {
+		e.Count >>= 1
+	}
+	for len(t.entries) > 0 && t.entries[0].Count == 0 {
+		heap.Pop(t)
+	}
+}
+
+func (t *topK) Len() int { return len(t.entries) }
+
+func (t *topK) Less(i, j int) bool { return t.entries[i].Count < t.entries[j].Count }
+
+func (t *topK) Swap(i, j int) {
+	t.entries[i], t.entries[j] = t.entries[j], t.entries[i]
+	t.index[t.entries[i].Key] = i
+	t.index[t.entries[j].Key] = j
+}
+
+func (t *topK) Push(x any) {
+	e := x.(*topEntry)
+	t.index[e.Key] = len(t.entries)
+	t.entries = append(t.entries, e)
+}
+
+func (t *topK) Pop() any {
+	old := t.entries
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	t.entries = old[:n-1]
+	delete(t.index, e.Key)
+	return e
+}
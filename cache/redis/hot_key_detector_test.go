@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHotKeyDetector(t *testing.T) {
+	d := NewHotKeyDetector(5, 100)
+	hot := []string{"celebrity-1", "celebrity-2", "celebrity-3"}
+	// 热点的访问夹杂在大量只访问一次的 key 中间
+	for i := 0; i < 20000; i++ {
+		d.Record(fmt.Sprintf("cold-%d", i))
+		if i%20 == 0 {
+			for j, k := range hot {
+				// celebrity-1 最热
+				for n := 0; n < len(hot)-j; n++ {
+					d.Record(k)
+				}
+			}
+		}
+	}
+
+	keys := d.HotKeys()
+	require.Len(t, keys, 3)
+	for i, k := range keys {
+		assert.Equal(t, hot[i], k.Key)
+		assert.True(t, d.IsHot(k.Key))
+	}
+	// 估算的次数不会偏差太多，celebrity-1 实际访问了 3000 次
+	assert.InDelta(t, 3000, keys[0].Count, 300)
+	assert.False(t, d.IsHot("cold-1"))
+
+	// 不再访问之后慢慢冷下来，celebrity-3 减半之前是 1000 -> 500 -> 250 -> 125 -> 62
+	for i := 0; i < 5; i++ {
+		d.Decay()
+	}
+	assert.False(t, d.IsHot("celebrity-3"))
+	// celebrity-1 是 3000 -> 1500 -> 750 -> 375 -> 187 -> 93
+	assert.True(t, d.IsHot("celebrity-1"))
+	d.Decay()
+	assert.Empty(t, d.HotKeys())
+	assert.False(t, d.IsHot("celebrity-1"))
+}
+
+func TestHotKeyDetector_SteadyRate(t *testing.T) {
+	d := NewHotKeyDetector(5, 10)
+	// 每个窗口访问 7 次，减半之后再访问就是 3 + 7 = 10，
+	// 一直在 threshold/2 和 threshold 之间，不能每个窗口都进出一次
+	for i := 0; i < 7; i++ {
+		d.Record("steady")
+	}
+	assert.False(t, d.IsHot("steady"))
+	for w := 0; w < 10; w++ {
+		d.Decay()
+		for i := 0; i < 7; i++ {
+			d.Record("steady")
+		}
+		if w > 0 {
+			assert.True(t, d.IsHot("steady"))
+		}
+	}
+
+	// 一直达不到 threshold 的 key 不会变成热点
+	for w := 0; w < 10; w++ {
+		for i := 0; i < 4; i++ {
+			assert.False(t, d.Record("warm"))
+		}
+		d.Decay()
+	}
+}
+
+func BenchmarkHotKeyDetector_Record(b *testing.B) {
+	d := NewHotKeyDetector(100, 1000)
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			d.Record(keys[i%len(keys)])
+			i++
+		}
+	})
+}
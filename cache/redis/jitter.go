@@ -0,0 +1,183 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/luxpo/time-go2nd/clock"
+)
+
+var (
+	_ Cache = (*JitterCache)(nil)
+
+	errUnsupportedSoftTTLValue = errors.New("cache: soft TTL 在 redis 里面只支持 string 和 []byte")
+)
+
+// JitterCache 给过期时间加上随机的抖动，防止大量 key 同时过期引起缓存雪崩。
+// 可以同时开启软过期：软过期之后 GetWithStale 会返回 stale = true，
+// 调用方可以在真正过期之前提前刷新
+type JitterCache struct {
+	Cache
+	jitter func(expiration time.Duration) time.Duration
+	// softRatio 为 0 表示不开启软过期
+	softRatio float64
+	clock     clock.Clock
+}
+
+type JitterCacheOption func(c *JitterCache)
+
+func NewJitterCache(c Cache, opts ...JitterCacheOption) *JitterCache {
+	jc := &JitterCache{
+		Cache: c,
+		jitter: func(expiration time.Duration) time.Duration {
+			return expiration
+		},
+		clock: clock.New(),
+	}
+	for _, opt := range opts {
+		opt(jc)
+	}
+	return jc
+}
+
+// JitterCacheWithPercentage 过期时间在 [expiration*(1-p), expiration*(1+p)] 之间随机
+func JitterCacheWithPercentage(p float64) JitterCacheOption {
+	return func(c *JitterCache) {
+		c.jitter = func(expiration time.Duration) time.Duration {
+			delta := float64(expiration) * p * (rand.Float64()*2 - 1)
+			return expiration + time.Duration(delta)
+		}
+	}
+}
+
+// JitterCacheWithRange 过期时间加上 [min, max) 之间的一个随机值
+func JitterCacheWithRange(min, max time.Duration) JitterCacheOption {
+	return func(c *JitterCache) {
+		c.jitter = func(expiration time.Duration) time.Duration {
+			if max <= min {
+				return expiration + min
+			}
+			return expiration + min + time.Duration(rand.Int63n(int64(max-min)))
+		}
+	}
+}
+
+// JitterCacheWithClock 软过期用的时钟，一般只在测试里面使用
+func JitterCacheWithClock(clk clock.Clock) JitterCacheOption {
+	return func(c *JitterCache) {
+		c.clock = clk
+	}
+}
+
+// JitterCacheWithSoftTTL 在 ratio 比例的过期时间之后把数据标记为 stale，ratio 在 (0, 1) 之间。
+// 开启之后值会被包装起来，写到 redis 里面的时候只支持 string 和 []byte，
+// RedisCache 设置了 serializer 也一样，其它类型的值 Set 会返回错误
+func JitterCacheWithSoftTTL(ratio float64) JitterCacheOption {
+	return func(c *JitterCache) {
+		c.softRatio = ratio
+	}
+}
+
+func (j *JitterCache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	if expiration <= 0 {
+		return j.Cache.Set(ctx, key, val, expiration)
+	}
+	expiration = j.jitter(expiration)
+	if expiration <= 0 {
+		// 抖动之后不能变成永不过期
+		expiration = time.Millisecond
+	}
+	if j.softRatio > 0 {
+		soft := time.Duration(float64(expiration) * j.softRatio)
+		val = &softEntry{Val: val, SoftDeadline: j.clock.Now().Add(soft)}
+	}
+	return j.Cache.Set(ctx, key, val, expiration)
+}
+
+func (j *JitterCache) Get(ctx context.Context, key string) (any, error) {
+	val, _, err := j.GetWithStale(ctx, key)
+	return val, err
+}
+
+// GetWithStale stale 为 true 表示已经过了软过期时间，值依旧可用，但是应该刷新了
+func (j *JitterCache) GetWithStale(ctx context.Context, key string) (any, bool, error) {
+	val, err := j.Cache.Get(ctx, key)
+	if err != nil {
+		return nil, false, err
+	}
+	entry, ok := decodeSoftEntry(val)
+	if !ok {
+		return val, false, nil
+	}
+	return entry.Val, !j.clock.Now().Before(entry.SoftDeadline), nil
+}
+
+func (j *JitterCache) LoadAndDelete(ctx context.Context, key string) (any, error) {
+	val, err := j.Cache.LoadAndDelete(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if entry, ok := decodeSoftEntry(val); ok {
+		return entry.Val, nil
+	}
+	return val, nil
+}
+
+// softEntryPrefix 以 \x00 开头，正常的文本不会和它冲突
+const softEntryPrefix = "\x00softttl:"
+
+// softEntry 本地缓存里面直接存这个结构体，
+// redis 里面通过 MarshalBinary 存成 前缀 + 软过期时间 + ":" + 值
+type softEntry struct {
+	Val          any
+	SoftDeadline time.Time
+}
+
+func (s *softEntry) MarshalBinary() ([]byte, error) {
+	var payload []byte
+	switch v := s.Val.(type) {
+	case string:
+		payload = []byte(v)
+	case []byte:
+		payload = v
+	default:
+		return nil, fmt.Errorf("%w, type: %T", errUnsupportedSoftTTLValue, s.Val)
+	}
+	var buf bytes.Buffer
+	buf.WriteString(softEntryPrefix)
+	buf.WriteString(strconv.FormatInt(s.SoftDeadline.UnixNano(), 10))
+	buf.WriteByte(':')
+	buf.Write(payload)
+	return buf.Bytes(), nil
+}
+
+func (*softEntry) selfEncoded() {}
+
+func decodeSoftEntry(val any) (*softEntry, bool) {
+	switch v := val.(type) {
+	case *softEntry:
+		return v, true
+	case string:
+		if !strings.HasPrefix(v, softEntryPrefix) {
+			return nil, false
+		}
+		rest := v[len(softEntryPrefix):]
+		idx := strings.IndexByte(rest, ':')
+		if idx < 0 {
+			return nil, false
+		}
+		nanos, err := strconv.ParseInt(rest[:idx], 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		return &softEntry{Val: rest[idx+1:], SoftDeadline: time.Unix(0, nanos)}, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	v3 "github.com/luxpo/time-go2nd/cache/local/v3"
+	"github.com/luxpo/time-go2nd/cache/redis/mocks"
+	"github.com/luxpo/time-go2nd/clock"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJitterCache_Set(t *testing.T) {
+	testCases := []struct {
+		name    string
+		opt     JitterCacheOption
+		wantMin time.Duration
+		wantMax time.Duration
+	}{
+		{
+			name:    "percentage",
+			opt:     JitterCacheWithPercentage(0.1),
+			wantMin: 90 * time.Second,
+			wantMax: 110 * time.Second,
+		},
+		{
+			name:    "range",
+			opt:     JitterCacheWithRange(time.Second, 5*time.Second),
+			wantMin: 101 * time.Second,
+			wantMax: 105 * time.Second,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			cmd := mocks.NewMockCmdable(ctrl)
+			var expirations []time.Duration
+			cmd.EXPECT().Set(gomock.Any(), "k1", "v1", gomock.Any()).
+				DoAndReturn(func(ctx context.Context, key string, val any, expiration time.Duration) *redis.StatusCmd {
+					expirations = append(expirations, expiration)
+					status := redis.NewStatusCmd(ctx)
+					status.SetVal("OK")
+					return status
+				}).Times(100)
+
+			c := NewJitterCache(NewRedisCache(cmd), tc.opt)
+			for i := 0; i < 100; i++ {
+				require.NoError(t, c.Set(context.Background(), "k1", "v1", 100*time.Second))
+			}
+			distinct := make(map[time.Duration]struct{})
+			for _, exp := range expirations {
+				assert.GreaterOrEqual(t, exp, tc.wantMin)
+				assert.LessOrEqual(t, exp, tc.wantMax)
+				distinct[exp] = struct{}{}
+			}
+			// 确实是随机的，不会全部一样
+			assert.Greater(t, len(distinct), 1)
+		})
+	}
+}
+
+func TestJitterCache_SoftTTL(t *testing.T) {
+	lc := v3.NewLocalCache(time.Minute)
+	defer func() {
+		_ = lc.Close()
+	}()
+	clk := clock.NewFakeClock(time.Now())
+	c := NewJitterCache(lc, JitterCacheWithSoftTTL(0.5), JitterCacheWithClock(clk))
+
+	require.NoError(t, c.Set(context.Background(), "k1", map[string]int{"a": 1}, 10*time.Second))
+	val, stale, err := c.GetWithStale(context.Background(), "k1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"a": 1}, val)
+	assert.False(t, stale)
+
+	clk.Advance(5 * time.Second)
+	val, stale, err = c.GetWithStale(context.Background(), "k1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"a": 1}, val)
+	assert.True(t, stale)
+
+	val, err = c.LoadAndDelete(context.Background(), "k1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"a": 1}, val)
+}
+
+func TestJitterCache_SoftTTLRedis(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := mocks.NewMockCmdable(ctrl)
+
+	var stored []byte
+	cmd.EXPECT().Set(gomock.Any(), "k1", gomock.Any(), 10*time.Second).
+		DoAndReturn(func(ctx context.Context, key string, val any, expiration time.Duration) *redis.StatusCmd {
+			// 模拟 go-redis 写入的过程
+			data, err := val.(*softEntry).MarshalBinary()
+			require.NoError(t, err)
+			stored = data
+			status := redis.NewStatusCmd(ctx)
+			status.SetVal("OK")
+			return status
+		})
+	cmd.EXPECT().Get(gomock.Any(), "k1").
+		DoAndReturn(func(ctx context.Context, key string) *redis.StringCmd {
+			str := redis.NewStringCmd(ctx)
+			str.SetVal(string(stored))
+			return str
+		}).Times(2)
+
+	clk := clock.NewFakeClock(time.Now())
+	c := NewJitterCache(NewRedisCache(cmd), JitterCacheWithSoftTTL(0.8), JitterCacheWithClock(clk))
+	require.NoError(t, c.Set(context.Background(), "k1", "v1", 10*time.Second))
+
+	val, stale, err := c.GetWithStale(context.Background(), "k1")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", val)
+	assert.False(t, stale)
+
+	clk.Advance(9 * time.Second)
+	val, stale, err = c.GetWithStale(context.Background(), "k1")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", val)
+	assert.True(t, stale)
+
+	_, err = (&softEntry{Val: 123}).MarshalBinary()
+	assert.ErrorIs(t, err, errUnsupportedSoftTTLValue)
+}
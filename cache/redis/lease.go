@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"context"
+	_ "embed"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	//go:embed lua/lease_release.lua
+	luaLeaseRelease string
+	//go:embed lua/lease_refresh.lua
+	luaLeaseRefresh string
+)
+
+// Lease 是 Semaphore 和 RWLock 拿到的租约。
+// 租约到期之前没有续约的话会被自动释放，持有者崩溃了也不会一直占着名额
+type Lease struct {
+	client        redis.Cmdable
+	key           string
+	token         string
+	ttl           time.Duration
+	releaseScript string
+	refreshScript string
+	// acquiredAt 拿到租约的时间
+	acquiredAt time.Time
+
+	released    chan struct{}
+	releaseOnce sync.Once
+}
+
+func newLease(client redis.Cmdable, key, token string, ttl time.Duration, releaseScript, refreshScript string) *Lease {
+	return &Lease{
+		client:        client,
+		key:           key,
+		token:         token,
+		ttl:           ttl,
+		releaseScript: releaseScript,
+		refreshScript: refreshScript,
+		acquiredAt:    time.Now(),
+		released:      make(chan struct{}),
+	}
+}
+
+// Release 释放租约，同时停止 AutoRefresh
+func (l *Lease) Release(ctx context.Context) error {
+	l.releaseOnce.Do(func() {
+		close(l.released)
+	})
+	res, err := l.client.Eval(ctx, l.releaseScript, []string{l.key}, l.token).Int64()
+	if err != nil {
+		return err
+	}
+	if res != 1 {
+		return ErrLockNotHold
+	}
+	return nil
+}
+
+// Refresh 把租约重新延长 ttl，租约已经过期的时候返回 ErrLockNotHold
+func (l *Lease) Refresh(ctx context.Context) error {
+	res, err := l.client.Eval(ctx, l.refreshScript, []string{l.key}, l.token, l.ttl.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if res != 1 {
+		return ErrLockNotHold
+	}
+	return nil
+}
+
+// AutoRefresh 和 Lock.AutoRefresh 一样，Release 之后返回 nil
+func (l *Lease) AutoRefresh(interval time.Duration, timeout time.Duration) error {
+	return autoRefresh(l.Refresh, l.released, interval, timeout, l.ttl, l.acquiredAt)
+}
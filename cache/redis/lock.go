@@ -0,0 +1,188 @@
+package cache
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	//go:embed lua/unlock.lua
+	luaUnlock string
+	//go:embed lua/refresh.lua
+	luaRefresh string
+
+	// ErrFailedToPreemptLock 锁被别人拿着，重试之后也没有抢到
+	ErrFailedToPreemptLock = errors.New("cache: 抢锁失败")
+	// ErrLockNotHold 锁已经过期了，或者被别人拿走了
+	ErrLockNotHold = errors.New("cache: 未持有锁")
+)
+
+// LockClient 基于 redis 的分布式锁。
+// 加锁用 SET NX PX，值是一个随机的 token，释放和续约的时候用 lua 脚本比较 token，
+// 避免把别人的锁删掉
+type LockClient struct {
+	client redis.Cmdable
+}
+
+func NewLockClient(client redis.Cmdable) *LockClient {
+	return &LockClient{
+		client: client,
+	}
+}
+
+// TryLock 只尝试一次，锁被别人拿着的时候返回 ErrFailedToPreemptLock
+func (c *LockClient) TryLock(ctx context.Context, key string, expiration time.Duration) (*Lock, error) {
+	return c.Lock(ctx, key, expiration, NewMaxAttemptsRetry(NewFixedIntervalRetry(0), 0))
+}
+
+// Lock 抢锁失败的时候按照 retry 重试，直到 retry 放弃或者 ctx 过期
+func (c *LockClient) Lock(ctx context.Context, key string, expiration time.Duration, retry RetryStrategy) (*Lock, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	err = acquire(ctx, retry, func() (bool, error) {
+		return c.client.SetNX(ctx, key, token, expiration).Result()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newLock(c.client, key, token, expiration), nil
+}
+
+// acquire 调用 try 直到成功，失败的时候按照 retry 等待，
+// retry 放弃的时候返回 ErrFailedToPreemptLock
+func acquire(ctx context.Context, retry RetryStrategy, try func() (bool, error)) error {
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+	for {
+		ok, err := try()
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+
+		interval, ok := retry.Next()
+		if !ok {
+			return ErrFailedToPreemptLock
+		}
+		if timer == nil {
+			timer = time.NewTimer(interval)
+		} else {
+			timer.Reset(interval)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+type Lock struct {
+	client     redis.Cmdable
+	key        string
+	token      string
+	expiration time.Duration
+	// acquiredAt 拿到锁的时间，AutoRefresh 从这个时间开始计算锁什么时候过期
+	acquiredAt time.Time
+
+	unlock     chan struct{}
+	unlockOnce sync.Once
+}
+
+func newLock(client redis.Cmdable, key, token string, expiration time.Duration) *Lock {
+	return &Lock{
+		client:     client,
+		key:        key,
+		token:      token,
+		expiration: expiration,
+		acquiredAt: time.Now(),
+		unlock:     make(chan struct{}),
+	}
+}
+
+// Unlock 释放锁，同时停止 AutoRefresh
+func (l *Lock) Unlock(ctx context.Context) error {
+	l.unlockOnce.Do(func() {
+		close(l.unlock)
+	})
+	res, err := l.client.Eval(ctx, luaUnlock, []string{l.key}, l.token).Int64()
+	if err != nil {
+		return err
+	}
+	if res != 1 {
+		return ErrLockNotHold
+	}
+	return nil
+}
+
+// Refresh 把锁的过期时间重新设置为 expiration
+func (l *Lock) Refresh(ctx context.Context) error {
+	res, err := l.client.Eval(ctx, luaRefresh, []string{l.key}, l.token, l.expiration.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if res != 1 {
+		return ErrLockNotHold
+	}
+	return nil
+}
+
+// AutoRefresh 每隔 interval 续约一次，每次续约最多等 timeout。
+// 续约超时会马上重试，距离上一次续约成功超过了 expiration 的时候锁肯定已经过期了，返回 ErrLockNotHold；
+// 其它错误直接返回。返回错误之后调用方需要认为自己已经不再持有锁了。
+// Unlock 之后返回 nil。这个方法会阻塞，一般在单独的 goroutine 里面调用
+func (l *Lock) AutoRefresh(interval time.Duration, timeout time.Duration) error {
+	return autoRefresh(l.Refresh, l.unlock, interval, timeout, l.expiration, l.acquiredAt)
+}
+
+// autoRefresh expiration 是每次续约成功之后的有效时间，acquiredAt 是拿到锁的时间
+func autoRefresh(refresh func(ctx context.Context) error, stop <-chan struct{},
+	interval, timeout, expiration time.Duration, acquiredAt time.Time) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	retry := make(chan struct{}, 1)
+	// 拿到锁的时候相当于续约成功了一次
+	lastRefreshed := acquiredAt
+	for {
+		select {
+		case <-ticker.C:
+		case <-retry:
+		case <-stop:
+			return nil
+		}
+		// 续约成功的时候，锁的有效期最晚也是从发出请求的时候开始算的
+		start := time.Now()
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := refresh(ctx)
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) {
+			if time.Since(lastRefreshed) >= expiration {
+				return fmt.Errorf("%w: %w", ErrLockNotHold, err)
+			}
+			// ticker 和 retry 同时就绪的时候可能先选中 ticker，retry 里面还有一个没有被消费
+			select {
+			case retry <- struct{}{}:
+			default:
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		lastRefreshed = start
+	}
+}
@@ -0,0 +1,284 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/luxpo/time-go2nd/cache/redis/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockClient_Lock(t *testing.T) {
+	testCases := []struct {
+		name  string
+		mock  func(ctrl *gomock.Controller) redis.Cmdable
+		retry RetryStrategy
+
+		wantErr error
+	}{
+		{
+			name: "locked",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				res := redis.NewBoolCmd(context.Background())
+				res.SetVal(true)
+				cmd.EXPECT().SetNX(gomock.Any(), "lock-key", gomock.Any(), time.Minute).Return(res)
+				return cmd
+			},
+			retry: NewMaxAttemptsRetry(NewFixedIntervalRetry(time.Millisecond), 3),
+		},
+		{
+			name: "locked after retry",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				failed := redis.NewBoolCmd(context.Background())
+				failed.SetVal(false)
+				cmd.EXPECT().SetNX(gomock.Any(), "lock-key", gomock.Any(), time.Minute).Return(failed).Times(2)
+				res := redis.NewBoolCmd(context.Background())
+				res.SetVal(true)
+				cmd.EXPECT().SetNX(gomock.Any(), "lock-key", gomock.Any(), time.Minute).Return(res)
+				return cmd
+			},
+			retry: NewMaxAttemptsRetry(NewFixedIntervalRetry(time.Millisecond), 3),
+		},
+		{
+			name: "retry exhausted",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				failed := redis.NewBoolCmd(context.Background())
+				failed.SetVal(false)
+				cmd.EXPECT().SetNX(gomock.Any(), "lock-key", gomock.Any(), time.Minute).Return(failed).Times(3)
+				return cmd
+			},
+			retry:   NewMaxAttemptsRetry(NewExponentialBackoffRetry(time.Millisecond, 2*time.Millisecond), 2),
+			wantErr: ErrFailedToPreemptLock,
+		},
+		{
+			name: "redis error",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				res := redis.NewBoolCmd(context.Background())
+				res.SetErr(errors.New("mock error"))
+				cmd.EXPECT().SetNX(gomock.Any(), "lock-key", gomock.Any(), time.Minute).Return(res)
+				return cmd
+			},
+			retry:   NewMaxAttemptsRetry(NewFixedIntervalRetry(time.Millisecond), 3),
+			wantErr: errors.New("mock error"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			c := NewLockClient(tc.mock(ctrl))
+			l, err := c.Lock(context.Background(), "lock-key", time.Minute, tc.retry)
+			if tc.wantErr != nil {
+				assert.EqualError(t, err, tc.wantErr.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "lock-key", l.key)
+			assert.NotEmpty(t, l.token)
+		})
+	}
+}
+
+func TestLockClient_LockContextDone(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := mocks.NewMockCmdable(ctrl)
+	failed := redis.NewBoolCmd(context.Background())
+	failed.SetVal(false)
+	cmd.EXPECT().SetNX(gomock.Any(), "lock-key", gomock.Any(), time.Minute).Return(failed).AnyTimes()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := NewLockClient(cmd).Lock(ctx, "lock-key", time.Minute, NewFixedIntervalRetry(5*time.Millisecond))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestLock_Unlock(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) redis.Cmdable
+
+		wantErr error
+	}{
+		{
+			name: "unlocked",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				res := redis.NewCmd(context.Background())
+				res.SetVal(int64(1))
+				cmd.EXPECT().Eval(gomock.Any(), luaUnlock, []string{"lock-key"}, "token").Return(res)
+				return cmd
+			},
+		},
+		{
+			name: "lock not hold",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				res := redis.NewCmd(context.Background())
+				res.SetVal(int64(0))
+				cmd.EXPECT().Eval(gomock.Any(), luaUnlock, []string{"lock-key"}, "token").Return(res)
+				return cmd
+			},
+			wantErr: ErrLockNotHold,
+		},
+		{
+			name: "redis error",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				res := redis.NewCmd(context.Background())
+				res.SetErr(context.DeadlineExceeded)
+				cmd.EXPECT().Eval(gomock.Any(), luaUnlock, []string{"lock-key"}, "token").Return(res)
+				return cmd
+			},
+			wantErr: context.DeadlineExceeded,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			l := newLock(tc.mock(ctrl), "lock-key", "token", time.Minute)
+			err := l.Unlock(context.Background())
+			assert.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestLock_Refresh(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) redis.Cmdable
+
+		wantErr error
+	}{
+		{
+			name: "refreshed",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				res := redis.NewCmd(context.Background())
+				res.SetVal(int64(1))
+				cmd.EXPECT().Eval(gomock.Any(), luaRefresh, []string{"lock-key"}, "token", int64(60000)).Return(res)
+				return cmd
+			},
+		},
+		{
+			name: "lock not hold",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				res := redis.NewCmd(context.Background())
+				res.SetVal(int64(0))
+				cmd.EXPECT().Eval(gomock.Any(), luaRefresh, []string{"lock-key"}, "token", int64(60000)).Return(res)
+				return cmd
+			},
+			wantErr: ErrLockNotHold,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			l := newLock(tc.mock(ctrl), "lock-key", "token", time.Minute)
+			err := l.Refresh(context.Background())
+			assert.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestLock_AutoRefresh(t *testing.T) {
+	t.Run("stop after unlock", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		cmd := mocks.NewMockCmdable(ctrl)
+		refreshed := make(chan struct{}, 10)
+		res := redis.NewCmd(context.Background())
+		res.SetVal(int64(1))
+		cmd.EXPECT().Eval(gomock.Any(), luaRefresh, []string{"lock-key"}, "token", int64(60000)).
+			DoAndReturn(func(ctx context.Context, script string, keys []string, args ...any) *redis.Cmd {
+				refreshed <- struct{}{}
+				return res
+			}).MinTimes(1)
+		cmd.EXPECT().Eval(gomock.Any(), luaUnlock, []string{"lock-key"}, "token").Return(res)
+
+		l := newLock(cmd, "lock-key", "token", time.Minute)
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- l.AutoRefresh(time.Millisecond, time.Second)
+		}()
+		<-refreshed
+		require.NoError(t, l.Unlock(context.Background()))
+		assert.NoError(t, <-errCh)
+	})
+
+	t.Run("retry on timeout", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		cmd := mocks.NewMockCmdable(ctrl)
+		timeout := redis.NewCmd(context.Background())
+		timeout.SetErr(context.DeadlineExceeded)
+		notHold := redis.NewCmd(context.Background())
+		notHold.SetVal(int64(0))
+		gomock.InOrder(
+			cmd.EXPECT().Eval(gomock.Any(), luaRefresh, []string{"lock-key"}, "token", int64(60000)).Return(timeout),
+			cmd.EXPECT().Eval(gomock.Any(), luaRefresh, []string{"lock-key"}, "token", int64(60000)).Return(notHold),
+		)
+
+		l := newLock(cmd, "lock-key", "token", time.Minute)
+		err := l.AutoRefresh(50*time.Millisecond, time.Second)
+		assert.ErrorIs(t, err, ErrLockNotHold)
+	})
+
+	t.Run("give up after expiration", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		cmd := mocks.NewMockCmdable(ctrl)
+		var attempts atomic.Int32
+		cmd.EXPECT().Eval(gomock.Any(), luaRefresh, []string{"lock-key"}, "token", int64(100)).
+			DoAndReturn(func(ctx context.Context, script string, keys []string, args ...any) *redis.Cmd {
+				attempts.Add(1)
+				// 模拟 redis 一直没有响应
+				<-ctx.Done()
+				res := redis.NewCmd(ctx)
+				res.SetErr(ctx.Err())
+				return res
+			}).MinTimes(1)
+
+		l := newLock(cmd, "lock-key", "token", time.Millisecond*100)
+		// 一直超时的话，过了锁的过期时间就不再重试了
+		err := l.AutoRefresh(10*time.Millisecond, 20*time.Millisecond)
+		assert.ErrorIs(t, err, ErrLockNotHold)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.LessOrEqual(t, attempts.Load(), int32(6))
+	})
+
+	t.Run("expired before auto refresh", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		cmd := mocks.NewMockCmdable(ctrl)
+		cmd.EXPECT().Eval(gomock.Any(), luaRefresh, []string{"lock-key"}, "token", int64(100)).
+			DoAndReturn(func(ctx context.Context, script string, keys []string, args ...any) *redis.Cmd {
+				<-ctx.Done()
+				res := redis.NewCmd(ctx)
+				res.SetErr(ctx.Err())
+				return res
+			})
+
+		l := newLock(cmd, "lock-key", "token", time.Millisecond*100)
+		// 拿到锁之后过了很久才开始自动续约，要从拿到锁的时候开始算
+		l.acquiredAt = time.Now().Add(-time.Millisecond * 100)
+		err := l.AutoRefresh(10*time.Millisecond, 20*time.Millisecond)
+		assert.ErrorIs(t, err, ErrLockNotHold)
+	})
+}
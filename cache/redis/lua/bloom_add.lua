@@ -0,0 +1,10 @@
+-- KEYS[1] 过滤器，KEYS[2] 重建用的临时 key，ARGV 是要置 1 的位
+-- 正在重建的时候临时 key 是存在的，同时写两份，避免 RENAME 之后丢掉重建期间加入的 key
+local rebuilding = redis.call("exists", KEYS[2])
+for i = 1, #ARGV do
+    redis.call("setbit", KEYS[1], ARGV[i], 1)
+    if rebuilding == 1 then
+        redis.call("setbit", KEYS[2], ARGV[i], 1)
+    end
+end
+return rebuilding
@@ -0,0 +1,4 @@
+-- KEYS[1] 重建用的临时 key，ARGV[1] 位数组的最后一位
+-- 清掉上一次没有完成的重建，并且马上创建临时 key，之后的 Add 会同时写到这里
+redis.call("del", KEYS[1])
+return redis.call("setbit", KEYS[1], ARGV[1], 0)
@@ -0,0 +1,12 @@
+-- KEYS[1] 持有者的 zset，ARGV[1] token，ARGV[2] 租约（毫秒）
+local t = redis.call("time")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local score = redis.call("zscore", KEYS[1], ARGV[1])
+-- 已经过期的租约不能续，名额可能已经被别人拿走了
+if not score or tonumber(score) <= now then
+    return 0
+end
+redis.call("zadd", KEYS[1], "XX", now + tonumber(ARGV[2]), ARGV[1])
+-- 每个租约一样长，最后续约的那个一定是最晚过期的
+redis.call("pexpire", KEYS[1], ARGV[2])
+return 1
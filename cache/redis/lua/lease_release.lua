@@ -0,0 +1,2 @@
+-- KEYS[1] 持有者的 zset，ARGV[1] token
+return redis.call("zrem", KEYS[1], ARGV[1])
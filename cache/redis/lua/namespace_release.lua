@@ -0,0 +1,8 @@
+-- KEYS 和 namespace_reserve.lua 一样，ARGV[1] key
+local size = redis.call("hget", KEYS[1], ARGV[1])
+if size then
+    redis.call("decrby", KEYS[3], size)
+    redis.call("hdel", KEYS[1], ARGV[1])
+end
+redis.call("zrem", KEYS[2], ARGV[1])
+return 1
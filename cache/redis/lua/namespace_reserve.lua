@@ -0,0 +1,45 @@
+-- KEYS[1] 每个 key 占用的内存（hash），KEYS[2] 每个 key 的过期时间（zset，毫秒），KEYS[3] 总共占用的内存
+-- ARGV[1] key，ARGV[2] 占用的内存，ARGV[3] 过期时间（毫秒），0 表示永不过期
+-- ARGV[4] 最多多少个 key，ARGV[5] 最多占用多少内存，0 表示不限制
+-- 返回 0 表示超过配额，1 表示新的 key，2 表示覆盖已有的 key
+local t = redis.call("time")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+-- 先把已经过期的去掉，每次最多清理 100 个，避免脚本执行太久
+local expired = redis.call("zrangebyscore", KEYS[2], "-inf", now, "limit", 0, 100)
+for _, k in ipairs(expired) do
+    local size = redis.call("hget", KEYS[1], k)
+    if size then
+        redis.call("decrby", KEYS[3], size)
+        redis.call("hdel", KEYS[1], k)
+    end
+    redis.call("zrem", KEYS[2], k)
+end
+
+local size = tonumber(ARGV[2])
+local maxKeys = tonumber(ARGV[4])
+local maxMemory = tonumber(ARGV[5])
+local old = redis.call("hget", KEYS[1], ARGV[1])
+local res = 2
+if old then
+    old = tonumber(old)
+else
+    if maxKeys > 0 and redis.call("hlen", KEYS[1]) >= maxKeys then
+        return 0
+    end
+    old = 0
+    res = 1
+end
+-- 覆盖的时候旧的值占用的内存可以释放
+local used = tonumber(redis.call("get", KEYS[3]) or "0")
+if maxMemory > 0 and used - old + size > maxMemory then
+    return 0
+end
+
+redis.call("hset", KEYS[1], ARGV[1], size)
+redis.call("incrby", KEYS[3], size - old)
+if tonumber(ARGV[3]) > 0 then
+    redis.call("zadd", KEYS[2], now + tonumber(ARGV[3]), ARGV[1])
+else
+    redis.call("zrem", KEYS[2], ARGV[1])
+end
+return res
@@ -0,0 +1,14 @@
+-- KEYS 和 namespace_reserve.lua 一样，返回 {key 的数量, 占用的内存}
+local t = redis.call("time")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+-- 先把已经过期的去掉，和 namespace_reserve.lua 一样每次最多清理 100 个，剩下的下一次再清理
+local expired = redis.call("zrangebyscore", KEYS[2], "-inf", now, "limit", 0, 100)
+for _, k in ipairs(expired) do
+    local size = redis.call("hget", KEYS[1], k)
+    if size then
+        redis.call("decrby", KEYS[3], size)
+        redis.call("hdel", KEYS[1], k)
+    end
+    redis.call("zrem", KEYS[2], k)
+end
+return {redis.call("hlen", KEYS[1]), tonumber(redis.call("get", KEYS[3]) or "0")}
@@ -0,0 +1,11 @@
+-- KEYS[1] 读锁持有者的 zset，KEYS[2] 写锁
+-- ARGV[1] token，ARGV[2] 租约（毫秒）
+if redis.call("exists", KEYS[2]) == 1 then
+    return 0
+end
+local t = redis.call("time")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+redis.call("zremrangebyscore", KEYS[1], "-inf", now)
+redis.call("zadd", KEYS[1], now + tonumber(ARGV[2]), ARGV[1])
+redis.call("pexpire", KEYS[1], ARGV[2])
+return 1
@@ -0,0 +1,13 @@
+-- KEYS[1] 持有者的 zset，score 是租约到期的时间（毫秒）
+-- ARGV[1] token，ARGV[2] 最多几个持有者，ARGV[3] 租约（毫秒）
+-- 用 redis 的时间，避免不同实例的时钟不一致，需要 redis 5 以上
+local t = redis.call("time")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+-- 清理掉已经崩溃、没有续约的持有者
+redis.call("zremrangebyscore", KEYS[1], "-inf", now)
+if redis.call("zcard", KEYS[1]) >= tonumber(ARGV[2]) then
+    return 0
+end
+redis.call("zadd", KEYS[1], now + tonumber(ARGV[3]), ARGV[1])
+redis.call("pexpire", KEYS[1], ARGV[3])
+return 1
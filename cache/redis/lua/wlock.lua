@@ -0,0 +1,13 @@
+-- KEYS[1] 读锁持有者的 zset，KEYS[2] 写锁
+-- ARGV[1] token，ARGV[2] 租约（毫秒）
+if redis.call("exists", KEYS[2]) == 1 then
+    return 0
+end
+local t = redis.call("time")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+redis.call("zremrangebyscore", KEYS[1], "-inf", now)
+if redis.call("zcard", KEYS[1]) > 0 then
+    return 0
+end
+redis.call("set", KEYS[2], ARGV[1], "px", ARGV[2])
+return 1
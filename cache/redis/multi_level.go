@@ -0,0 +1,172 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	_ Cache = (*MultiLevelCache)(nil)
+
+	// ErrFailedToInvalidate redis 已经写成功了，但是没能通知其它实例，
+	// 它们的本地缓存要等过期之后才会更新
+	ErrFailedToInvalidate = errors.New("cache: 发布失效消息失败")
+)
+
+// Subscriber *redis.Client、*redis.ClusterClient 都实现了这个接口
+type Subscriber interface {
+	Subscribe(ctx context.Context, channels ...string) *redis.PubSub
+}
+
+// MultiLevelCache 两级缓存，L1 是本地缓存（一般是 v3.LocalCache），L2 是 redis。
+// 读的时候先读 L1，没有再读 L2，读到之后用一个比较短的过期时间写回 L1。
+// 写和删除只操作 L2，删掉自己的 L1，然后通过 pub/sub 通知其它实例删掉它们的 L1。
+// pub/sub 不保证送达，断线期间的消息会丢失，所以 L1 的过期时间是脏数据最长的存活时间
+type MultiLevelCache struct {
+	local  Cache
+	remote Cache
+	client redis.Cmdable
+
+	channel         string
+	localExpiration time.Duration
+	// instanceID 用来忽略自己发出去的消息
+	instanceID string
+	// gens 用来发现写回 L1 期间发生的失效
+	gens generations
+}
+
+type MultiLevelCacheOption func(c *MultiLevelCache)
+
+// NewMultiLevelCache client 用来发布失效消息，一般和 remote 用的是同一个
+func NewMultiLevelCache(local Cache, remote Cache, client redis.Cmdable,
+	opts ...MultiLevelCacheOption) (*MultiLevelCache, error) {
+	id, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	c := &MultiLevelCache{
+		local:           local,
+		remote:          remote,
+		client:          client,
+		channel:         "cache:invalidation",
+		localExpiration: time.Minute,
+		instanceID:      id,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// MultiLevelCacheWithChannel 同一组实例要用同一个 channel
+func MultiLevelCacheWithChannel(channel string) MultiLevelCacheOption {
+	return func(c *MultiLevelCache) {
+		c.channel = channel
+	}
+}
+
+// MultiLevelCacheWithLocalExpiration 写回 L1 的时候用的过期时间，默认一分钟
+func MultiLevelCacheWithLocalExpiration(expiration time.Duration) MultiLevelCacheOption {
+	return func(c *MultiLevelCache) {
+		c.localExpiration = expiration
+	}
+}
+
+func (m *MultiLevelCache) Get(ctx context.Context, key string) (any, error) {
+	// L1 出了别的错误也当作没有命中
+	val, err := m.local.Get(ctx, key)
+	if err == nil {
+		return val, nil
+	}
+	gen := m.gens.load(key)
+	val, err = m.remote.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	// 写回失败不影响结果
+	_ = m.local.Set(ctx, key, val, m.localExpiration)
+	// 读 L2 期间 key 被失效了，写回去的可能是旧值
+	if m.gens.load(key) != gen {
+		_ = m.local.Delete(ctx, key)
+	}
+	return val, nil
+}
+
+func (m *MultiLevelCache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	if err := m.remote.Set(ctx, key, val, expiration); err != nil {
+		return err
+	}
+	return m.invalidate(ctx, key)
+}
+
+func (m *MultiLevelCache) Delete(ctx context.Context, key string) error {
+	if err := m.remote.Delete(ctx, key); err != nil {
+		return err
+	}
+	return m.invalidate(ctx, key)
+}
+
+func (m *MultiLevelCache) LoadAndDelete(ctx context.Context, key string) (any, error) {
+	val, err := m.remote.LoadAndDelete(ctx, key)
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return nil, err
+	}
+	if ierr := m.invalidate(ctx, key); ierr != nil {
+		return val, ierr
+	}
+	return val, err
+}
+
+// invalidate 删掉自己的 L1，然后通知其它实例
+func (m *MultiLevelCache) invalidate(ctx context.Context, key string) error {
+	m.deleteLocal(ctx, key)
+	err := m.client.Publish(ctx, m.channel, m.instanceID+" "+key).Err()
+	if err != nil {
+		return fmt.Errorf("%w, key: %s, 原因：%w", ErrFailedToInvalidate, key, err)
+	}
+	return nil
+}
+
+// Subscribe 订阅其它实例的失效消息，阻塞直到 ctx 被取消，
+// 一般在启动的时候单独起一个 goroutine 调用
+func (m *MultiLevelCache) Subscribe(ctx context.Context, sub Subscriber) error {
+	ps := sub.Subscribe(ctx, m.channel)
+	defer func() {
+		_ = ps.Close()
+	}()
+	// 等订阅成功之后再返回消息，避免订阅失败的时候一直静默
+	if _, err := ps.Receive(ctx); err != nil {
+		return err
+	}
+	ch := ps.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			m.handleMessage(ctx, msg.Payload)
+		}
+	}
+}
+
+func (m *MultiLevelCache) handleMessage(ctx context.Context, payload string) {
+	id, key, ok := strings.Cut(payload, " ")
+	if !ok || id == m.instanceID {
+		return
+	}
+	m.deleteLocal(ctx, key)
+}
+
+// deleteLocal 要先更新 generation 再删除，参考 generations
+func (m *MultiLevelCache) deleteLocal(ctx context.Context, key string) {
+	m.gens.bump(key)
+	_ = m.local.Delete(ctx, key)
+}
@@ -0,0 +1,154 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	v3 "github.com/luxpo/time-go2nd/cache/local/v3"
+	"github.com/luxpo/time-go2nd/cache/redis/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiLevelCache_Get(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := mocks.NewMockCmdable(ctrl)
+	str := redis.NewStringCmd(context.Background())
+	str.SetVal("v1")
+	// 只有第一次会读 redis
+	cmd.EXPECT().Get(gomock.Any(), "k1").Return(str).Times(1)
+	missing := redis.NewStringCmd(context.Background())
+	missing.SetErr(redis.Nil)
+	cmd.EXPECT().Get(gomock.Any(), "k2").Return(missing)
+
+	lc := v3.NewLocalCache(time.Minute)
+	defer func() {
+		_ = lc.Close()
+	}()
+	c, err := NewMultiLevelCache(lc, NewRedisCache(cmd), cmd)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		val, err := c.Get(context.Background(), "k1")
+		require.NoError(t, err)
+		assert.Equal(t, "v1", val)
+	}
+	_, err = c.Get(context.Background(), "k2")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestMultiLevelCache_Set(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mock    func(ctrl *gomock.Controller) redis.Cmdable
+		wantErr error
+	}{
+		{
+			name: "published",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				status := redis.NewStatusCmd(context.Background())
+				status.SetVal("OK")
+				cmd.EXPECT().Set(gomock.Any(), "k1", "v2", time.Minute).Return(status)
+				res := redis.NewIntCmd(context.Background())
+				res.SetVal(1)
+				cmd.EXPECT().Publish(gomock.Any(), "cache:invalidation", gomock.Any()).Return(res)
+				return cmd
+			},
+		},
+		{
+			name: "publish failed",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				status := redis.NewStatusCmd(context.Background())
+				status.SetVal("OK")
+				cmd.EXPECT().Set(gomock.Any(), "k1", "v2", time.Minute).Return(status)
+				res := redis.NewIntCmd(context.Background())
+				res.SetErr(errors.New("mock error"))
+				cmd.EXPECT().Publish(gomock.Any(), "cache:invalidation", gomock.Any()).Return(res)
+				return cmd
+			},
+			wantErr: ErrFailedToInvalidate,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			lc := v3.NewLocalCache(time.Minute)
+			defer func() {
+				_ = lc.Close()
+			}()
+			require.NoError(t, lc.Set(context.Background(), "k1", "v1", time.Minute))
+			cmd := tc.mock(ctrl)
+			c, err := NewMultiLevelCache(lc, NewRedisCache(cmd), cmd)
+			require.NoError(t, err)
+
+			err = c.Set(context.Background(), "k1", "v2", time.Minute)
+			assert.ErrorIs(t, err, tc.wantErr)
+			// 自己的 L1 总是会被删掉
+			_, err = lc.Get(context.Background(), "k1")
+			assert.ErrorIs(t, err, ErrKeyNotFound)
+		})
+	}
+}
+
+func TestMultiLevelCache_handleMessage(t *testing.T) {
+	lc := v3.NewLocalCache(time.Minute)
+	defer func() {
+		_ = lc.Close()
+	}()
+	c, err := NewMultiLevelCache(lc, nil, nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+	require.NoError(t, lc.Set(ctx, "k1", "v1", time.Minute))
+	require.NoError(t, lc.Set(ctx, "k 2", "v2", time.Minute))
+
+	// 自己发出去的消息
+	c.handleMessage(ctx, c.instanceID+" k1")
+	_, err = lc.Get(ctx, "k1")
+	require.NoError(t, err)
+
+	c.handleMessage(ctx, "other k1")
+	_, err = lc.Get(ctx, "k1")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+
+	// key 里面可以有空格
+	c.handleMessage(ctx, "other k 2")
+	_, err = lc.Get(ctx, "k 2")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestMultiLevelCache_GetInvalidatedDuringLoad(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := mocks.NewMockCmdable(ctrl)
+	lc := v3.NewLocalCache(time.Minute)
+	defer func() {
+		_ = lc.Close()
+	}()
+	c, err := NewMultiLevelCache(lc, NewRedisCache(cmd), cmd)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	cmd.EXPECT().Get(gomock.Any(), "k1").
+		DoAndReturn(func(ctx context.Context, key string) *redis.StringCmd {
+			// 读到旧值之后，写回 L1 之前，别的实例修改了 k1
+			c.handleMessage(ctx, "other k1")
+			str := redis.NewStringCmd(ctx)
+			str.SetVal("old")
+			return str
+		})
+	val, err := c.Get(ctx, "k1")
+	require.NoError(t, err)
+	assert.Equal(t, "old", val)
+	// 旧值不能留在 L1 里面
+	_, err = lc.Get(ctx, "k1")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+}
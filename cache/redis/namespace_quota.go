@@ -0,0 +1,190 @@
+package cache
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/luxpo/time-go2nd/clock"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	_ namespaceQuota = (*localNamespaceQuota)(nil)
+	_ namespaceQuota = (*redisNamespaceQuota)(nil)
+
+	//go:embed lua/namespace_reserve.lua
+	luaNamespaceReserve string
+	//go:embed lua/namespace_release.lua
+	luaNamespaceRelease string
+	//go:embed lua/namespace_usage.lua
+	luaNamespaceUsage string
+)
+
+// namespaceQuota 统计一个命名空间的 key 数量和占用的内存，key 是没有前缀的原始 key
+type namespaceQuota interface {
+	// reserve 超过配额的时候返回 ErrNamespaceQuotaExceeded，created 表示 key 之前不存在
+	reserve(ctx context.Context, key string, size int64, expiration time.Duration) (created bool, err error)
+	// release 释放配额失败的话只是多算了，不影响结果
+	release(ctx context.Context, key string)
+	usage(ctx context.Context) (NamespaceUsage, error)
+	// reset 清空所有的计数
+	reset(ctx context.Context) error
+}
+
+// localNamespaceQuota 在进程内计数，多个实例各自按照配额限制
+type localNamespaceQuota struct {
+	mu      sync.Mutex
+	entries map[string]namespaceEntry
+	used    int64
+
+	clock     clock.Clock
+	maxKeys   int
+	maxMemory int64
+}
+
+type namespaceEntry struct {
+	size     int64
+	deadline time.Time
+}
+
+func newLocalNamespaceQuota(clk clock.Clock, maxKeys int, maxMemory int64) *localNamespaceQuota {
+	return &localNamespaceQuota{
+		entries:   make(map[string]namespaceEntry),
+		clock:     clk,
+		maxKeys:   maxKeys,
+		maxMemory: maxMemory,
+	}
+}
+
+func (q *localNamespaceQuota) reserve(ctx context.Context, key string, size int64, expiration time.Duration) (bool, error) {
+	var dl time.Time
+	if expiration > 0 {
+		dl = q.clock.Now().Add(expiration)
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.fits(key, size) {
+		// 先把已经过期的去掉再试一次
+		q.pruneExpired()
+		if !q.fits(key, size) {
+			return false, ErrNamespaceQuotaExceeded
+		}
+	}
+	old, existed := q.entries[key]
+	q.used += size - old.size
+	q.entries[key] = namespaceEntry{size: size, deadline: dl}
+	return !existed, nil
+}
+
+// fits 调用方需要持有锁，覆盖的时候旧的值占用的空间可以释放
+func (q *localNamespaceQuota) fits(key string, size int64) bool {
+	old, ok := q.entries[key]
+	if !ok && q.maxKeys > 0 && len(q.entries)+1 > q.maxKeys {
+		return false
+	}
+	return q.maxMemory <= 0 || q.used-old.size+size <= q.maxMemory
+}
+
+// pruneExpired 调用方需要持有锁
+func (q *localNamespaceQuota) pruneExpired() {
+	now := q.clock.Now()
+	for k, e := range q.entries {
+		if !e.deadline.IsZero() && !e.deadline.After(now) {
+			q.forget(k)
+		}
+	}
+}
+
+// forget 调用方需要持有锁
+func (q *localNamespaceQuota) forget(key string) {
+	if e, ok := q.entries[key]; ok {
+		q.used -= e.size
+		delete(q.entries, key)
+	}
+}
+
+func (q *localNamespaceQuota) release(ctx context.Context, key string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.forget(key)
+}
+
+func (q *localNamespaceQuota) usage(ctx context.Context) (NamespaceUsage, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pruneExpired()
+	return NamespaceUsage{
+		Keys:   len(q.entries),
+		Memory: q.used,
+	}, nil
+}
+
+func (q *localNamespaceQuota) reset(ctx context.Context) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.entries = make(map[string]namespaceEntry)
+	q.used = 0
+	return nil
+}
+
+// redisNamespaceQuota 把计数保存在 redis 里面，多个实例共享同一份配额
+type redisNamespaceQuota struct {
+	client redis.Cmdable
+	// keys 保存计数的三个 key，用 hash tag 保证在同一个 slot
+	keys []string
+
+	maxKeys   int
+	maxMemory int64
+}
+
+func newRedisNamespaceQuota(client redis.Cmdable, namespace string, maxKeys int, maxMemory int64) *redisNamespaceQuota {
+	key := "cache:quota:{" + namespace + "}"
+	return &redisNamespaceQuota{
+		client:    client,
+		keys:      []string{key + ":sizes", key + ":deadlines", key + ":used"},
+		maxKeys:   maxKeys,
+		maxMemory: maxMemory,
+	}
+}
+
+func (q *redisNamespaceQuota) reserve(ctx context.Context, key string, size int64, expiration time.Duration) (bool, error) {
+	ttl := expiration.Milliseconds()
+	if expiration > 0 && ttl == 0 {
+		// 不到一毫秒的也不能变成永不过期
+		ttl = 1
+	}
+	res, err := q.client.Eval(ctx, luaNamespaceReserve, q.keys,
+		key, size, ttl, q.maxKeys, q.maxMemory).Int()
+	if err != nil {
+		return false, err
+	}
+	if res == 0 {
+		return false, ErrNamespaceQuotaExceeded
+	}
+	return res == 1, nil
+}
+
+func (q *redisNamespaceQuota) release(ctx context.Context, key string) {
+	_ = q.client.Eval(ctx, luaNamespaceRelease, q.keys, key).Err()
+}
+
+func (q *redisNamespaceQuota) usage(ctx context.Context) (NamespaceUsage, error) {
+	res, err := q.client.Eval(ctx, luaNamespaceUsage, q.keys).Int64Slice()
+	if err != nil {
+		return NamespaceUsage{}, err
+	}
+	if len(res) != 2 {
+		return NamespaceUsage{}, fmt.Errorf("cache: 非法的返回值 %v", res)
+	}
+	return NamespaceUsage{
+		Keys:   int(res[0]),
+		Memory: res[1],
+	}, nil
+}
+
+func (q *redisNamespaceQuota) reset(ctx context.Context) error {
+	return q.client.Del(ctx, q.keys...).Err()
+}
@@ -0,0 +1,259 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	v3 "github.com/luxpo/time-go2nd/cache/local/v3"
+	"github.com/luxpo/time-go2nd/clock"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	_ Cache = (*NamespacedCache)(nil)
+
+	// ErrNamespaceQuotaExceeded 命名空间的 key 数量或者占用的内存超过了配额
+	ErrNamespaceQuotaExceeded = errors.New("cache: 超过了命名空间的配额")
+
+	errFlushNotSupported = errors.New("cache: 被装饰的缓存不支持按照前缀删除")
+)
+
+// PrefixFlusher 支持按照前缀删除 key，RedisCache 和 v3.LocalCache 都实现了这个接口
+type PrefixFlusher interface {
+	// FlushPrefix 删除所有以 prefix 开头的 key，返回删除的数量
+	FlushPrefix(ctx context.Context, prefix string) (int64, error)
+}
+
+// NamespacedCache 给 key 加上 namespace: 前缀，多个团队共用一个缓存的时候互相隔离。
+// 同时统计这个命名空间的 key 数量和占用的内存，超过配额的时候拒绝写入新的数据。
+// 默认在进程内计数，统计的是经过这个实例写入的 key，适合 v3.LocalCache 这种本地缓存；
+// 多个实例共用一个 redis 的时候用 NamespacedCacheWithRedisQuota 共享同一份配额。
+// 只统计经过 NamespacedCache 写入的 key，被底层缓存淘汰的 key 要等 Get 的时候发现不存在才会释放配额
+type NamespacedCache struct {
+	c         Cache
+	namespace string
+	prefix    string
+	quota     namespaceQuota
+
+	// 下面的字段只用来创建 quota
+	client    redis.Cmdable
+	clock     clock.Clock
+	maxKeys   int
+	maxMemory int64
+
+	sizer v3.Sizer
+}
+
+// NamespaceUsage 命名空间当前的使用情况
+type NamespaceUsage struct {
+	Keys   int
+	Memory int64
+}
+
+type NamespacedCacheOption func(c *NamespacedCache)
+
+func NewNamespacedCache(c Cache, namespace string, opts ...NamespacedCacheOption) *NamespacedCache {
+	nc := &NamespacedCache{
+		c:         c,
+		namespace: namespace,
+		prefix:    namespace + ":",
+		clock:     clock.New(),
+		sizer:     v3.DefaultSizer,
+	}
+	for _, opt := range opts {
+		opt(nc)
+	}
+	if nc.client != nil {
+		nc.quota = newRedisNamespaceQuota(nc.client, namespace, nc.maxKeys, nc.maxMemory)
+	} else {
+		nc.quota = newLocalNamespaceQuota(nc.clock, nc.maxKeys, nc.maxMemory)
+	}
+	return nc
+}
+
+// NamespacedCacheWithMaxKeys 最多多少个 key，0 表示不限制
+func NamespacedCacheWithMaxKeys(maxKeys int) NamespacedCacheOption {
+	return func(c *NamespacedCache) {
+		c.maxKeys = maxKeys
+	}
+}
+
+// NamespacedCacheWithMaxMemory 最多占用多少字节（key 加上值），0 表示不限制
+func NamespacedCacheWithMaxMemory(maxMemory int64) NamespacedCacheOption {
+	return func(c *NamespacedCache) {
+		c.maxMemory = maxMemory
+	}
+}
+
+// NamespacedCacheWithSizer 默认是 v3.DefaultSizer，只能计算 string 和 []byte 的大小，
+// 其它类型在没有内存配额的时候按照 0 计算，有内存配额的时候直接报错
+func NamespacedCacheWithSizer(sizer v3.Sizer) NamespacedCacheOption {
+	return func(c *NamespacedCache) {
+		c.sizer = sizer
+	}
+}
+
+// NamespacedCacheWithRedisQuota 把配额的计数保存在 client 里面，一般和被装饰的缓存用的是同一个 redis。
+// 检查和增加在同一个 lua 脚本里面完成，所以多个实例共享同一份配额，重启之后也不会丢失
+func NamespacedCacheWithRedisQuota(client redis.Cmdable) NamespacedCacheOption {
+	return func(c *NamespacedCache) {
+		c.client = client
+	}
+}
+
+// NamespacedCacheWithClock 进程内计数的时候用来判断过期，一般只在测试里面使用
+func NamespacedCacheWithClock(clk clock.Clock) NamespacedCacheOption {
+	return func(c *NamespacedCache) {
+		c.clock = clk
+	}
+}
+
+// Set 先占住配额再写缓存。写缓存失败的时候，新的 key 会释放配额；
+// 覆盖已有的 key 失败的时候按照新的值计算，直到下一次写入
+func (n *NamespacedCache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	size, err := n.sizer(val)
+	if err != nil {
+		if n.maxMemory > 0 {
+			return err
+		}
+		size = 0
+	}
+	size += int64(len(n.prefix) + len(key))
+
+	created, err := n.quota.reserve(ctx, key, size, expiration)
+	if err != nil {
+		if errors.Is(err, ErrNamespaceQuotaExceeded) {
+			return fmt.Errorf("%w, namespace: %s, key: %s", err, n.namespace, key)
+		}
+		return err
+	}
+
+	if err = n.c.Set(ctx, n.prefix+key, val, expiration); err != nil {
+		if created {
+			n.quota.release(ctx, key)
+		}
+		return err
+	}
+	return nil
+}
+
+func (n *NamespacedCache) Get(ctx context.Context, key string) (any, error) {
+	val, err := n.c.Get(ctx, n.prefix+key)
+	if errors.Is(err, ErrKeyNotFound) {
+		// 过期了，或者被底层的缓存淘汰了
+		n.quota.release(ctx, key)
+	}
+	return val, err
+}
+
+func (n *NamespacedCache) Delete(ctx context.Context, key string) error {
+	if err := n.c.Delete(ctx, n.prefix+key); err != nil {
+		return err
+	}
+	n.quota.release(ctx, key)
+	return nil
+}
+
+func (n *NamespacedCache) LoadAndDelete(ctx context.Context, key string) (any, error) {
+	val, err := n.c.LoadAndDelete(ctx, n.prefix+key)
+	if err == nil || errors.Is(err, ErrKeyNotFound) {
+		n.quota.release(ctx, key)
+	}
+	return val, err
+}
+
+// Usage 返回当前的 key 数量和占用的内存，已经过期的 key 不算在里面。
+// 配额保存在 redis 里面的时候每次最多清理 100 个过期的 key，过期的 key 很多的时候会多算一些
+func (n *NamespacedCache) Usage(ctx context.Context) (NamespaceUsage, error) {
+	return n.quota.usage(ctx)
+}
+
+// FlushNamespace 删除这个命名空间下面的所有 key，包括别的实例写入的，同时清空配额的计数
+func (n *NamespacedCache) FlushNamespace(ctx context.Context) error {
+	f, ok := n.c.(PrefixFlusher)
+	if !ok {
+		return fmt.Errorf("%w, type: %T", errFlushNotSupported, n.c)
+	}
+	if _, err := f.FlushPrefix(ctx, n.prefix); err != nil {
+		return err
+	}
+	return n.quota.reset(ctx)
+}
+
+// FlushPrefix 用 SCAN 找到所有以 prefix 开头的 key，再用 UNLINK 删除。
+// UNLINK 在后台释放内存，不会像 DEL 那样阻塞 redis。
+// SCAN 只会遍历一个节点，所以 *redis.ClusterClient 会在每一个主节点上面分别遍历
+func (c *RedisCache) FlushPrefix(ctx context.Context, prefix string) (int64, error) {
+	match := escapeGlob(prefix) + "*"
+	cc, ok := c.client.(*redis.ClusterClient)
+	if !ok {
+		return flushMatched(ctx, c.client, match, false)
+	}
+	var cnt atomic.Int64
+	err := cc.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+		n, err := flushMatched(ctx, client, match, true)
+		cnt.Add(n)
+		return err
+	})
+	return cnt.Load(), err
+}
+
+// flushMatched 删除 client 上面所有匹配 match 的 key。
+// 集群的一个节点上面的 key 分布在不同的 slot 里面，不能一次 UNLINK 多个，
+// 这个时候 perKey 为 true，用 pipeline 一个一个地删
+func flushMatched(ctx context.Context, client redis.Cmdable, match string, perKey bool) (int64, error) {
+	var cursor uint64
+	var cnt int64
+	for {
+		keys, next, err := client.Scan(ctx, cursor, match, 1000).Result()
+		if err != nil {
+			return cnt, err
+		}
+		if len(keys) > 0 {
+			n, err := unlink(ctx, client, keys, perKey)
+			cnt += n
+			if err != nil {
+				return cnt, err
+			}
+		}
+		if next == 0 {
+			return cnt, nil
+		}
+		cursor = next
+	}
+}
+
+func unlink(ctx context.Context, client redis.Cmdable, keys []string, perKey bool) (int64, error) {
+	if !perKey {
+		return client.Unlink(ctx, keys...).Result()
+	}
+	cmds := make([]*redis.IntCmd, len(keys))
+	_, err := client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for i, k := range keys {
+			cmds[i] = p.Unlink(ctx, k)
+		}
+		return nil
+	})
+	var cnt int64
+	for _, cmd := range cmds {
+		cnt += cmd.Val()
+	}
+	return cnt, err
+}
+
+// escapeGlob 转义 SCAN MATCH 里面的特殊字符，避免前缀里面的 * 之类的匹配到别的 key
+func escapeGlob(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\', '^', '-':
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
@@ -0,0 +1,335 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	v3 "github.com/luxpo/time-go2nd/cache/local/v3"
+	"github.com/luxpo/time-go2nd/cache/redis/mocks"
+	"github.com/luxpo/time-go2nd/clock"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeQuota 用 map 模拟 namespace_*.lua 维护的计数
+type fakeQuota struct {
+	sizes     map[string]int64
+	deadlines map[string]time.Time
+	used      int64
+}
+
+// fakeQuotaStore 模拟 redis 执行 namespace_*.lua，按照 KEYS[1] 区分不同的命名空间
+type fakeQuotaStore struct {
+	mu     sync.Mutex
+	quotas map[string]*fakeQuota
+	now    time.Time
+}
+
+func newFakeQuotaStore(ctrl *gomock.Controller) (*fakeQuotaStore, redis.Cmdable) {
+	f := &fakeQuotaStore{
+		quotas: make(map[string]*fakeQuota),
+		now:    time.Now(),
+	}
+	cmd := mocks.NewMockCmdable(ctrl)
+	cmd.EXPECT().Eval(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(f.eval).AnyTimes()
+	cmd.EXPECT().Del(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, keys ...string) *redis.IntCmd {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			delete(f.quotas, keys[0])
+			return redis.NewIntResult(int64(len(keys)), nil)
+		}).AnyTimes()
+	return f, cmd
+}
+
+func (f *fakeQuotaStore) advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+func (f *fakeQuotaStore) eval(ctx context.Context, script string, keys []string, args ...any) *redis.Cmd {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	q, ok := f.quotas[keys[0]]
+	if !ok {
+		q = &fakeQuota{sizes: map[string]int64{}, deadlines: map[string]time.Time{}}
+		f.quotas[keys[0]] = q
+	}
+	for k, dl := range q.deadlines {
+		if !dl.After(f.now) {
+			q.release(k)
+		}
+	}
+
+	cmd := redis.NewCmd(ctx)
+	switch script {
+	case luaNamespaceReserve:
+		key, size, ttl := args[0].(string), args[1].(int64), args[2].(int64)
+		maxKeys, maxMemory := args[3].(int), args[4].(int64)
+		old, existed := q.sizes[key]
+		if !existed && maxKeys > 0 && len(q.sizes) >= maxKeys ||
+			maxMemory > 0 && q.used-old+size > maxMemory {
+			cmd.SetVal(int64(0))
+			return cmd
+		}
+		q.sizes[key] = size
+		q.used += size - old
+		delete(q.deadlines, key)
+		if ttl > 0 {
+			q.deadlines[key] = f.now.Add(time.Duration(ttl) * time.Millisecond)
+		}
+		if existed {
+			cmd.SetVal(int64(2))
+		} else {
+			cmd.SetVal(int64(1))
+		}
+	case luaNamespaceRelease:
+		q.release(args[0].(string))
+		cmd.SetVal(int64(1))
+	case luaNamespaceUsage:
+		cmd.SetVal([]any{int64(len(q.sizes)), q.used})
+	default:
+		cmd.SetErr(errors.New("unknown script"))
+	}
+	return cmd
+}
+
+func (q *fakeQuota) release(key string) {
+	q.used -= q.sizes[key]
+	delete(q.sizes, key)
+	delete(q.deadlines, key)
+}
+
+func TestNamespacedCache_Quota(t *testing.T) {
+	testCases := []struct {
+		name string
+		// newCache 返回的 advance 让配额的时钟往前走
+		newCache func(t *testing.T, c Cache, opts ...NamespacedCacheOption) (*NamespacedCache, func(d time.Duration))
+	}{
+		{
+			name: "local",
+			newCache: func(t *testing.T, c Cache, opts ...NamespacedCacheOption) (*NamespacedCache, func(d time.Duration)) {
+				clk := clock.NewFakeClock(time.Now())
+				opts = append(opts, NamespacedCacheWithClock(clk))
+				return NewNamespacedCache(c, "team-a", opts...), clk.Advance
+			},
+		},
+		{
+			name: "redis",
+			newCache: func(t *testing.T, c Cache, opts ...NamespacedCacheOption) (*NamespacedCache, func(d time.Duration)) {
+				ctrl := gomock.NewController(t)
+				t.Cleanup(ctrl.Finish)
+				store, cmd := newFakeQuotaStore(ctrl)
+				opts = append(opts, NamespacedCacheWithRedisQuota(cmd))
+				return NewNamespacedCache(c, "team-a", opts...), store.advance
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lc := v3.NewLocalCache(time.Minute)
+			defer func() {
+				_ = lc.Close()
+			}()
+			ctx := context.Background()
+			c, advance := tc.newCache(t, lc, NamespacedCacheWithMaxKeys(2), NamespacedCacheWithMaxMemory(40))
+			usage := func() NamespaceUsage {
+				u, err := c.Usage(ctx)
+				require.NoError(t, err)
+				return u
+			}
+
+			require.NoError(t, c.Set(ctx, "k1", "v1", time.Minute))
+			require.NoError(t, c.Set(ctx, "k2", "v2", time.Second))
+			// 透明地加上了前缀
+			val, err := lc.Get(ctx, "team-a:k1")
+			require.NoError(t, err)
+			assert.Equal(t, "v1", val)
+			val, err = c.Get(ctx, "k1")
+			require.NoError(t, err)
+			assert.Equal(t, "v1", val)
+			// 每个 key 占 len("team-a:k1") + len("v1") = 11
+			assert.Equal(t, NamespaceUsage{Keys: 2, Memory: 22}, usage())
+
+			err = c.Set(ctx, "k3", "v3", time.Minute)
+			assert.ErrorIs(t, err, ErrNamespaceQuotaExceeded)
+			// 覆盖不算新的 key，但是内存会超
+			require.NoError(t, c.Set(ctx, "k1", "v1-new", time.Minute))
+			err = c.Set(ctx, "k1", "a very long value......", time.Minute)
+			assert.ErrorIs(t, err, ErrNamespaceQuotaExceeded)
+			assert.Equal(t, NamespaceUsage{Keys: 2, Memory: 26}, usage())
+
+			// k2 过期之后释放配额
+			advance(time.Second * 2)
+			require.NoError(t, c.Set(ctx, "k3", "v3", time.Minute))
+			require.NoError(t, c.Delete(ctx, "k3"))
+			assert.Equal(t, NamespaceUsage{Keys: 1, Memory: 15}, usage())
+
+			// 被底层缓存淘汰的 key 在 Get 的时候释放配额
+			require.NoError(t, c.Set(ctx, "k4", "v4", time.Minute))
+			require.NoError(t, lc.Delete(ctx, "team-a:k4"))
+			_, err = c.Get(ctx, "k4")
+			assert.ErrorIs(t, err, ErrKeyNotFound)
+			assert.Equal(t, NamespaceUsage{Keys: 1, Memory: 15}, usage())
+
+			// 没有内存配额的时候，计算不出大小的值也能写
+			c2, _ := tc.newCache(t, lc)
+			require.NoError(t, c2.Set(ctx, "k5", 123, time.Minute))
+			_, err = c2.Get(ctx, "k5")
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestNamespacedCache_RedisQuotaShared(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	lc := v3.NewLocalCache(time.Minute)
+	defer func() {
+		_ = lc.Close()
+	}()
+	ctx := context.Background()
+	_, cmd := newFakeQuotaStore(ctrl)
+	a := NewNamespacedCache(lc, "team-a", NamespacedCacheWithMaxKeys(2), NamespacedCacheWithRedisQuota(cmd))
+	b := NewNamespacedCache(lc, "team-a", NamespacedCacheWithMaxKeys(2), NamespacedCacheWithRedisQuota(cmd))
+
+	// 配额是所有实例共享的
+	require.NoError(t, a.Set(ctx, "k1", "v1", time.Minute))
+	require.NoError(t, b.Set(ctx, "k2", "v2", time.Minute))
+	assert.ErrorIs(t, a.Set(ctx, "k3", "v3", time.Minute), ErrNamespaceQuotaExceeded)
+
+	// 清空命名空间的时候计数也一起清掉
+	require.NoError(t, a.FlushNamespace(ctx))
+	u, err := b.Usage(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, NamespaceUsage{}, u)
+
+	// 进程内计数的时候每个实例各算各的
+	c := NewNamespacedCache(lc, "team-a", NamespacedCacheWithMaxKeys(2))
+	d := NewNamespacedCache(lc, "team-a", NamespacedCacheWithMaxKeys(2))
+	require.NoError(t, c.Set(ctx, "k1", "v1", time.Minute))
+	require.NoError(t, c.Set(ctx, "k2", "v2", time.Minute))
+	require.NoError(t, d.Set(ctx, "k3", "v3", time.Minute))
+}
+
+// failingSetCache 的 Set 总是失败
+type failingSetCache struct {
+	Cache
+}
+
+func (c failingSetCache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	return errors.New("mock error")
+}
+
+func TestNamespacedCache_SetFailed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+	_, cmd := newFakeQuotaStore(ctrl)
+
+	for _, c := range []*NamespacedCache{
+		NewNamespacedCache(failingSetCache{}, "team-a", NamespacedCacheWithMaxKeys(1)),
+		NewNamespacedCache(failingSetCache{}, "team-a", NamespacedCacheWithMaxKeys(1),
+			NamespacedCacheWithRedisQuota(cmd)),
+	} {
+		// 写失败了要把配额还回去
+		assert.EqualError(t, c.Set(ctx, "k1", "v1", time.Minute), "mock error")
+		u, err := c.Usage(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, NamespaceUsage{}, u)
+	}
+}
+
+func TestNamespacedCache_FlushNamespace(t *testing.T) {
+	lc := v3.NewLocalCache(time.Minute)
+	defer func() {
+		_ = lc.Close()
+	}()
+	ctx := context.Background()
+	a := NewNamespacedCache(lc, "team-a")
+	b := NewNamespacedCache(lc, "team-b")
+	require.NoError(t, a.Set(ctx, "k1", "v1", time.Minute))
+	require.NoError(t, a.Set(ctx, "k2", "v2", time.Minute))
+	require.NoError(t, b.Set(ctx, "k1", "v1", time.Minute))
+
+	require.NoError(t, a.FlushNamespace(ctx))
+	_, err := a.Get(ctx, "k1")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	u, err := a.Usage(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, NamespaceUsage{}, u)
+	// 别的命名空间不受影响
+	val, err := b.Get(ctx, "k1")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", val)
+	u, err = b.Usage(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, u.Keys)
+
+	// JitterCache 不支持按照前缀删除
+	err = NewNamespacedCache(NewJitterCache(lc), "team-c").FlushNamespace(ctx)
+	assert.ErrorIs(t, err, errFlushNotSupported)
+}
+
+func TestRedisCache_FlushPrefix(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := mocks.NewMockCmdable(ctrl)
+	page1 := redis.NewScanCmd(context.Background(), nil)
+	page1.SetVal([]string{"team*a:k1", "team*a:k2"}, 10)
+	page2 := redis.NewScanCmd(context.Background(), nil)
+	page2.SetVal(nil, 20)
+	page3 := redis.NewScanCmd(context.Background(), nil)
+	page3.SetVal([]string{"team*a:k3"}, 0)
+	// 前缀里面的 * 需要转义
+	gomock.InOrder(
+		cmd.EXPECT().Scan(gomock.Any(), uint64(0), `team\*a:*`, int64(1000)).Return(page1),
+		cmd.EXPECT().Unlink(gomock.Any(), "team*a:k1", "team*a:k2").Return(redis.NewIntResult(2, nil)),
+		cmd.EXPECT().Scan(gomock.Any(), uint64(10), `team\*a:*`, int64(1000)).Return(page2),
+		cmd.EXPECT().Scan(gomock.Any(), uint64(20), `team\*a:*`, int64(1000)).Return(page3),
+		cmd.EXPECT().Unlink(gomock.Any(), "team*a:k3").Return(redis.NewIntResult(1, nil)),
+	)
+
+	cnt, err := NewRedisCache(cmd).FlushPrefix(context.Background(), "team*a:")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), cnt)
+}
+
+// unlinkPipeliner 记录 pipeline 里面的 UNLINK
+type unlinkPipeliner struct {
+	redis.Pipeliner
+	keys []string
+}
+
+func (p *unlinkPipeliner) Unlink(ctx context.Context, keys ...string) *redis.IntCmd {
+	p.keys = append(p.keys, keys...)
+	return redis.NewIntResult(int64(len(keys)), nil)
+}
+
+func TestFlushMatched_PerKey(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := mocks.NewMockCmdable(ctrl)
+	page := redis.NewScanCmd(context.Background(), nil)
+	page.SetVal([]string{"team-a:k1", "team-a:k2"}, 0)
+	cmd.EXPECT().Scan(gomock.Any(), uint64(0), "team\\-a:*", int64(1000)).Return(page)
+	p := &unlinkPipeliner{}
+	cmd.EXPECT().Pipelined(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, fn func(redis.Pipeliner) error) ([]redis.Cmder, error) {
+			return nil, fn(p)
+		})
+
+	// 集群节点上面的 key 不在同一个 slot，要一个一个地删
+	cnt, err := flushMatched(context.Background(), cmd, escapeGlob("team-a:")+"*", true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), cnt)
+	assert.Equal(t, []string{"team-a:k1", "team-a:k2"}, p.keys)
+}
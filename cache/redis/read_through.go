@@ -0,0 +1,224 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/luxpo/time-go2nd/cache/internal/errs"
+	"github.com/luxpo/time-go2nd/cache/stats"
+)
+
+var (
+	_ Cache = (*ReadThroughCache)(nil)
+
+	// ErrFailedToRefreshCache 回源成功了，但是写缓存失败了，
+	// 这个时候 Get 依旧会返回加载到的值
+	ErrFailedToRefreshCache = errors.New("cache: 刷新缓存失败")
+	// ErrNegativeCached 数据源里面没有这个 key，并且这个结果被缓存了，
+	// 在负缓存过期之前不会再回源。
+	// 它和 ErrKeyNotFound 是两个错误，都需要当成不存在处理的时候要分别判断
+	ErrNegativeCached = errors.New("cache: 数据不存在（负缓存）")
+)
+
+// LoadFunc 从数据源加载数据。
+// 数据源里面也没有的时候，返回的错误需要能被 errors.Is(err, ErrKeyNotFound) 判断出来
+type LoadFunc func(ctx context.Context, key string) (any, error)
+
+// ReadThroughCache 缓存未命中的时候自动回源，加载成功之后写回缓存。
+// 用户不再需要自己写 cache-aside 的模板代码
+type ReadThroughCache struct {
+	Cache
+	loadFunc   LoadFunc
+	expiration time.Duration
+	// negativeExpiration 大于 0 的时候，数据源里面不存在的 key 也会被缓存
+	negativeExpiration time.Duration
+
+	stats stats.Recorder
+}
+
+type ReadThroughCacheOption func(c *ReadThroughCache)
+
+func NewReadThroughCache(c Cache, loadFunc LoadFunc, expiration time.Duration,
+	opts ...ReadThroughCacheOption) *ReadThroughCache {
+	rc := &ReadThroughCache{
+		Cache:      c,
+		loadFunc:   loadFunc,
+		expiration: expiration,
+	}
+	for _, opt := range opts {
+		opt(rc)
+	}
+	return rc
+}
+
+// ReadThroughCacheWithNegativeCache 数据源返回 ErrKeyNotFound 的时候，
+// 缓存一个"不存在"的标记，过期时间一般比正常数据短。
+// 在标记过期之前 Get 直接返回 ErrNegativeCached，不会再回源
+func ReadThroughCacheWithNegativeCache(expiration time.Duration) ReadThroughCacheOption {
+	return func(c *ReadThroughCache) {
+		c.negativeExpiration = expiration
+	}
+}
+
+func (r *ReadThroughCache) Get(ctx context.Context, key string) (any, error) {
+	val, err := r.get(ctx, key)
+	if err == nil || !errors.Is(err, ErrKeyNotFound) {
+		return val, err
+	}
+	return r.load(ctx, key)
+}
+
+func (r *ReadThroughCache) LoadAndDelete(ctx context.Context, key string) (any, error) {
+	val, err := r.Cache.LoadAndDelete(ctx, key)
+	if err == nil && isNegativeEntry(val) {
+		return nil, fmt.Errorf("%w, key: %s", ErrNegativeCached, key)
+	}
+	return val, err
+}
+
+// get 读缓存，把负缓存的标记转换成 ErrNegativeCached
+func (r *ReadThroughCache) get(ctx context.Context, key string) (any, error) {
+	val, err := r.Cache.Get(ctx, key)
+	if err == nil && isNegativeEntry(val) {
+		return nil, fmt.Errorf("%w, key: %s", ErrNegativeCached, key)
+	}
+	return val, err
+}
+
+func (r *ReadThroughCache) load(ctx context.Context, key string) (any, error) {
+	start := time.Now()
+	val, err := r.loadFunc(ctx, key)
+	r.stats.RecordLoad(time.Since(start), err)
+	if err != nil {
+		if r.negativeExpiration > 0 && errors.Is(err, ErrKeyNotFound) {
+			// 写负缓存失败不影响结果，下一次再回源就是了
+			_ = r.Cache.Set(ctx, key, negativeEntry{}, r.negativeExpiration)
+		}
+		return nil, err
+	}
+	if err = r.Cache.Set(ctx, key, val, r.expiration); err != nil {
+		return val, fmt.Errorf("%w, key: %s, 原因：%w", ErrFailedToRefreshCache, key, err)
+	}
+	return val, nil
+}
+
+// Stats 被装饰的缓存支持统计的时候，会带上它的统计数据
+func (r *ReadThroughCache) Stats() stats.Stats {
+	s := r.stats.Stats()
+	if p, ok := r.Cache.(stats.Provider); ok {
+		s.Merge(p.Stats())
+	}
+	return s
+}
+
+// negativeValue 是负缓存标记在 redis 里面的样子。
+// 以 \x00 开头，正常的文本不会和它冲突
+const negativeValue = "\x00cache:negative"
+
+// negativeEntry 负缓存的标记。
+// 本地缓存里面直接存这个类型，用户的值不可能是一个未导出的类型；
+// redis 里面通过 MarshalBinary 存成 negativeValue
+type negativeEntry struct{}
+
+func (negativeEntry) MarshalBinary() ([]byte, error) {
+	return []byte(negativeValue), nil
+}
+
+func (negativeEntry) selfEncoded() {}
+
+func isNegativeEntry(val any) bool {
+	switch v := val.(type) {
+	case negativeEntry:
+		return true
+	case string:
+		return v == negativeValue
+	default:
+		return false
+	}
+}
+
+// BatchLoadFunc 从数据源批量加载数据，数据源里面没有的 key 不放在返回的 map 里面就可以
+type BatchLoadFunc func(ctx context.Context, keys []string) (map[string]any, error)
+
+// BatchReadThroughCache 是批量版本的 ReadThroughCache，
+// MGet 的时候只回源缓存里面没有的 key。
+// 和 ReadThroughCache 共用缓存的时候，有负缓存标记的 key 对应的是 ErrNegativeCached
+type BatchReadThroughCache struct {
+	BatchCache
+	loadFunc   BatchLoadFunc
+	expiration time.Duration
+
+	stats stats.Recorder
+}
+
+func NewBatchReadThroughCache(c BatchCache, loadFunc BatchLoadFunc, expiration time.Duration) *BatchReadThroughCache {
+	return &BatchReadThroughCache{
+		BatchCache: c,
+		loadFunc:   loadFunc,
+		expiration: expiration,
+	}
+}
+
+// MGet 数据源里面也没有的 key，依旧对应一个 ErrKeyNotFound。
+// 回源成功但是写缓存失败的时候，返回加载到的值和 ErrFailedToRefreshCache
+func (r *BatchReadThroughCache) MGet(ctx context.Context, keys []string) (map[string]any, error) {
+	vals, err := r.BatchCache.MGet(ctx, keys)
+	// ReadThroughCache 写进去的负缓存标记也当成不存在，并且不回源
+	var errList []error
+	negative := make(map[string]struct{})
+	for k, val := range vals {
+		if isNegativeEntry(val) {
+			delete(vals, k)
+			negative[k] = struct{}{}
+			errList = append(errList, fmt.Errorf("%w, key: %s", ErrNegativeCached, k))
+		}
+	}
+	if err == nil {
+		return vals, errors.Join(errList...)
+	}
+	if vals == nil || !errors.Is(err, ErrKeyNotFound) {
+		return vals, errors.Join(append(errList, err)...)
+	}
+
+	missing := make([]string, 0, len(keys)-len(vals)-len(negative))
+	for _, k := range keys {
+		if _, ok := negative[k]; ok {
+			continue
+		}
+		if _, ok := vals[k]; !ok {
+			missing = append(missing, k)
+		}
+	}
+	start := time.Now()
+	loaded, err := r.loadFunc(ctx, missing)
+	r.stats.RecordLoad(time.Since(start), err)
+	if err != nil {
+		return vals, errors.Join(append(errList, err)...)
+	}
+
+	if len(loaded) > 0 {
+		if err = r.BatchCache.MSet(ctx, loaded, r.expiration); err != nil {
+			errList = append(errList, fmt.Errorf("%w, 原因：%w", ErrFailedToRefreshCache, err))
+		}
+	}
+	for _, k := range missing {
+		val, ok := loaded[k]
+		if !ok {
+			errList = append(errList, errs.NewErrKeyNotFound(k))
+			continue
+		}
+		vals[k] = val
+	}
+	return vals, errors.Join(errList...)
+}
+
+// Stats 参考 ReadThroughCache.Stats
+func (r *BatchReadThroughCache) Stats() stats.Stats {
+	s := r.stats.Stats()
+	if p, ok := r.BatchCache.(stats.Provider); ok {
+		s.Merge(p.Stats())
+	}
+	return s
+}
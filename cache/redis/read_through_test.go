@@ -0,0 +1,225 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	v3 "github.com/luxpo/time-go2nd/cache/local/v3"
+	"github.com/luxpo/time-go2nd/cache/redis/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadThroughCache_Get(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mock     func(ctrl *gomock.Controller) redis.Cmdable
+		loadFunc LoadFunc
+
+		wantVal   any
+		wantErr   error
+		wantLoads uint64
+	}{
+		{
+			name: "hit",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				str := redis.NewStringCmd(context.Background())
+				str.SetVal("v1")
+				cmd.EXPECT().Get(gomock.Any(), "k1").Return(str)
+				return cmd
+			},
+			wantVal: "v1",
+		},
+		{
+			name: "load",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				str := redis.NewStringCmd(context.Background())
+				str.SetErr(redis.Nil)
+				cmd.EXPECT().Get(gomock.Any(), "k1").Return(str)
+				status := redis.NewStatusCmd(context.Background())
+				status.SetVal("OK")
+				cmd.EXPECT().Set(gomock.Any(), "k1", "v1", time.Minute).Return(status)
+				return cmd
+			},
+			loadFunc: func(ctx context.Context, key string) (any, error) {
+				return "v1", nil
+			},
+			wantVal:   "v1",
+			wantLoads: 1,
+		},
+		{
+			name: "redis error",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				str := redis.NewStringCmd(context.Background())
+				str.SetErr(context.DeadlineExceeded)
+				cmd.EXPECT().Get(gomock.Any(), "k1").Return(str)
+				return cmd
+			},
+			// 不是 key 不存在，不会回源
+			wantErr: context.DeadlineExceeded,
+		},
+		{
+			name: "not found in source",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				str := redis.NewStringCmd(context.Background())
+				str.SetErr(redis.Nil)
+				cmd.EXPECT().Get(gomock.Any(), "k1").Return(str)
+				return cmd
+			},
+			loadFunc: func(ctx context.Context, key string) (any, error) {
+				return nil, ErrKeyNotFound
+			},
+			wantErr:   ErrKeyNotFound,
+			wantLoads: 1,
+		},
+		{
+			name: "refresh failed",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				str := redis.NewStringCmd(context.Background())
+				str.SetErr(redis.Nil)
+				cmd.EXPECT().Get(gomock.Any(), "k1").Return(str)
+				status := redis.NewStatusCmd(context.Background())
+				status.SetErr(errors.New("mock error"))
+				cmd.EXPECT().Set(gomock.Any(), "k1", "v1", time.Minute).Return(status)
+				return cmd
+			},
+			loadFunc: func(ctx context.Context, key string) (any, error) {
+				return "v1", nil
+			},
+			// 依旧返回加载到的值
+			wantVal:   "v1",
+			wantErr:   ErrFailedToRefreshCache,
+			wantLoads: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			c := NewReadThroughCache(NewRedisCache(tc.mock(ctrl)), tc.loadFunc, time.Minute)
+			val, err := c.Get(context.Background(), "k1")
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.wantVal, val)
+			assert.Equal(t, tc.wantLoads, c.Stats().Loads)
+		})
+	}
+}
+
+func TestRedisCache_GetKeyNotFound(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := mocks.NewMockCmdable(ctrl)
+	str := redis.NewStringCmd(context.Background())
+	str.SetErr(redis.Nil)
+	cmd.EXPECT().Get(gomock.Any(), "k1").Return(str)
+
+	_, err := NewRedisCache(cmd).Get(context.Background(), "k1")
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	// 兼容原来用 redis.Nil 判断的写法
+	assert.ErrorIs(t, err, redis.Nil)
+}
+
+func TestReadThroughCache_NegativeCache(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mock     func(ctrl *gomock.Controller) redis.Cmdable
+		loadFunc LoadFunc
+
+		wantErr   error
+		wantLoads uint64
+	}{
+		{
+			name: "cache not found",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				str := redis.NewStringCmd(context.Background())
+				str.SetErr(redis.Nil)
+				cmd.EXPECT().Get(gomock.Any(), "k1").Return(str)
+				status := redis.NewStatusCmd(context.Background())
+				status.SetVal("OK")
+				cmd.EXPECT().Set(gomock.Any(), "k1", negativeEntry{}, 10*time.Second).Return(status)
+				return cmd
+			},
+			loadFunc: func(ctx context.Context, key string) (any, error) {
+				return nil, ErrKeyNotFound
+			},
+			wantErr:   ErrKeyNotFound,
+			wantLoads: 1,
+		},
+		{
+			name: "negative cached",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				str := redis.NewStringCmd(context.Background())
+				// redis 里面读出来的是字符串
+				str.SetVal(negativeValue)
+				cmd.EXPECT().Get(gomock.Any(), "k1").Return(str)
+				return cmd
+			},
+			wantErr: ErrNegativeCached,
+		},
+		{
+			name: "other error not cached",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				str := redis.NewStringCmd(context.Background())
+				str.SetErr(redis.Nil)
+				cmd.EXPECT().Get(gomock.Any(), "k1").Return(str)
+				return cmd
+			},
+			loadFunc: func(ctx context.Context, key string) (any, error) {
+				return nil, errors.New("db error")
+			},
+			wantErr:   errors.New("db error"),
+			wantLoads: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			c := NewReadThroughCache(NewRedisCache(tc.mock(ctrl)), tc.loadFunc, time.Minute,
+				ReadThroughCacheWithNegativeCache(10*time.Second))
+			_, err := c.Get(context.Background(), "k1")
+			if errors.Is(tc.wantErr, ErrKeyNotFound) || errors.Is(tc.wantErr, ErrNegativeCached) {
+				assert.ErrorIs(t, err, tc.wantErr)
+			} else {
+				assert.EqualError(t, err, tc.wantErr.Error())
+			}
+			assert.Equal(t, tc.wantLoads, c.Stats().Loads)
+		})
+	}
+}
+
+func TestReadThroughCache_NegativeCacheLocal(t *testing.T) {
+	lc := v3.NewLocalCache(time.Minute)
+	defer func() {
+		_ = lc.Close()
+	}()
+	loads := 0
+	c := NewReadThroughCache(lc, func(ctx context.Context, key string) (any, error) {
+		loads++
+		return nil, ErrKeyNotFound
+	}, time.Minute, ReadThroughCacheWithNegativeCache(time.Minute))
+
+	_, err := c.Get(context.Background(), "k1")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	_, err = c.Get(context.Background(), "k1")
+	assert.ErrorIs(t, err, ErrNegativeCached)
+	assert.Equal(t, 1, loads)
+
+	_, err = c.LoadAndDelete(context.Background(), "k1")
+	assert.ErrorIs(t, err, ErrNegativeCached)
+}
@@ -6,26 +6,45 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/luxpo/time-go2nd/cache/internal/errs"
+	"github.com/luxpo/time-go2nd/cache/stats"
+	"github.com/luxpo/time-go2nd/micro/rpc2/serialize"
 	"github.com/redis/go-redis/v9"
 )
 
 var (
 	_ Cache = (*RedisCache)(nil)
 
+	// ErrKeyNotFound 本地缓存返回的也是这个错误，可以用 errors.Is 统一判断
+	ErrKeyNotFound = errs.ErrKeyNotFound
+
 	errFailedToSetCache = errors.New("cache: 写入 redis 失败")
 )
 
 type RedisCache struct {
 	client redis.Cmdable
+	// serializer 为 nil 的时候，值直接交给 go-redis 处理
+	serializer serialize.Serializer
+	stats      stats.Recorder
 }
 
-func NewRedisCache(client redis.Cmdable) *RedisCache {
-	return &RedisCache{
+type RedisCacheOption func(c *RedisCache)
+
+func NewRedisCache(client redis.Cmdable, opts ...RedisCacheOption) *RedisCache {
+	c := &RedisCache{
 		client: client,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 func (c *RedisCache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	val, err := c.encode(val)
+	if err != nil {
+		return err
+	}
 	res, err := c.client.Set(ctx, key, val, expiration).Result()
 	if err != nil {
 		return err
@@ -33,18 +52,53 @@ func (c *RedisCache) Set(ctx context.Context, key string, val any, expiration ti
 	if res != "OK" {
 		return fmt.Errorf("%w, 返回信息 %s", errFailedToSetCache, res)
 	}
+	c.stats.RecordSet()
 	return nil
 }
 
 func (c *RedisCache) Get(ctx context.Context, key string) (any, error) {
-	return c.client.Get(ctx, key).Result()
+	val, err := c.client.Get(ctx, key).Result()
+	switch {
+	case err == nil:
+		c.stats.RecordHit()
+	case errors.Is(err, redis.Nil):
+		c.stats.RecordMiss()
+		return nil, keyNotFound(key)
+	default:
+		return nil, err
+	}
+	return val, nil
 }
 
 func (c *RedisCache) Delete(ctx context.Context, key string) error {
 	_, err := c.client.Del(ctx, key).Result()
+	if err == nil {
+		c.stats.RecordDelete()
+	}
 	return err
 }
 
 func (c *RedisCache) LoadAndDelete(ctx context.Context, key string) (any, error) {
-	return c.client.GetDel(ctx, key).Result()
+	val, err := c.client.GetDel(ctx, key).Result()
+	switch {
+	case err == nil:
+		c.stats.RecordDelete()
+	case errors.Is(err, redis.Nil):
+		c.stats.RecordDelete()
+		return nil, keyNotFound(key)
+	default:
+		return nil, err
+	}
+	return val, nil
+}
+
+// Stats 返回当前实例的统计数据。
+// 只统计经过这个实例的请求，过期和淘汰由 redis 自己处理，这里统计不到，Size 也总是 0
+func (c *RedisCache) Stats() stats.Stats {
+	return c.stats.Stats()
+}
+
+// keyNotFound 同时保留 redis.Nil，之前用 redis.Nil 判断的调用方不受影响
+func keyNotFound(key string) error {
+	return fmt.Errorf("%w: %w", errs.NewErrKeyNotFound(key), redis.Nil)
 }
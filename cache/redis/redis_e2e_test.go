@@ -4,9 +4,11 @@ package cache
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
+	v3 "github.com/luxpo/time-go2nd/cache/local/v3"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -72,3 +74,212 @@ func TestRedisCache_e2e_SetV1(t *testing.T) {
 	}
 
 }
+
+func TestRedisBloomFilter_e2e(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	f, err := NewRedisBloomFilter(rdb, "bf:e2e", 1000, 0.01)
+	require.NoError(t, err)
+	require.NoError(t, f.Rebuild(ctx, []string{"k1", "k2"}))
+	require.NoError(t, f.Add(ctx, "k4"))
+	ok, err := f.HasKey(ctx, "k1")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = f.HasKey(ctx, "k3")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = f.HasKey(ctx, "k4")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	_, err = rdb.Del(ctx, "bf:e2e").Result()
+	require.NoError(t, err)
+}
+
+func TestLockClient_e2e(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	c := NewLockClient(rdb)
+
+	l, err := c.TryLock(ctx, "lock:e2e", time.Second)
+	require.NoError(t, err)
+	// 别人抢不到
+	_, err = c.TryLock(ctx, "lock:e2e", time.Second)
+	assert.ErrorIs(t, err, ErrFailedToPreemptLock)
+
+	go func() {
+		_ = l.AutoRefresh(time.Millisecond*200, time.Second)
+	}()
+	// 超过了过期时间，但是一直在续约
+	time.Sleep(time.Second * 2)
+	_, err = c.TryLock(ctx, "lock:e2e", time.Second)
+	assert.ErrorIs(t, err, ErrFailedToPreemptLock)
+
+	require.NoError(t, l.Unlock(ctx))
+	assert.ErrorIs(t, l.Unlock(ctx), ErrLockNotHold)
+
+	l2, err := c.Lock(ctx, "lock:e2e", time.Second,
+		NewMaxAttemptsRetry(NewFixedIntervalRetry(time.Millisecond*100), 3))
+	require.NoError(t, err)
+	require.NoError(t, l2.Unlock(ctx))
+}
+
+func TestSemaphore_e2e(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	s := NewSemaphore(rdb, "sem:e2e", 2, time.Millisecond*500)
+
+	l1, err := s.TryAcquire(ctx)
+	require.NoError(t, err)
+	l2, err := s.TryAcquire(ctx)
+	require.NoError(t, err)
+	_, err = s.TryAcquire(ctx)
+	assert.ErrorIs(t, err, ErrFailedToPreemptLock)
+
+	require.NoError(t, l1.Release(ctx))
+	l3, err := s.TryAcquire(ctx)
+	require.NoError(t, err)
+
+	// l2 和 l3 都不续约，过期之后名额自动释放
+	time.Sleep(time.Second)
+	assert.ErrorIs(t, l2.Refresh(ctx), ErrLockNotHold)
+	l4, err := s.Acquire(ctx, NewMaxAttemptsRetry(NewFixedIntervalRetry(time.Millisecond*100), 3))
+	require.NoError(t, err)
+	require.NoError(t, l4.Release(ctx))
+	assert.ErrorIs(t, l3.Release(ctx), ErrLockNotHold)
+}
+
+func TestRWLock_e2e(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	l := NewRWLock(rdb, "rw:e2e", time.Second)
+
+	r1, err := l.TryRLock(ctx)
+	require.NoError(t, err)
+	r2, err := l.TryRLock(ctx)
+	require.NoError(t, err)
+	_, err = l.TryLock(ctx)
+	assert.ErrorIs(t, err, ErrFailedToPreemptLock)
+
+	require.NoError(t, r1.Release(ctx))
+	require.NoError(t, r2.Release(ctx))
+	w, err := l.TryLock(ctx)
+	require.NoError(t, err)
+	_, err = l.TryRLock(ctx)
+	assert.ErrorIs(t, err, ErrFailedToPreemptLock)
+	require.NoError(t, w.Refresh(ctx))
+	require.NoError(t, w.Release(ctx))
+}
+
+func TestMultiLevelCache_e2e(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	// 模拟两个实例
+	lc1 := v3.NewLocalCache(time.Minute)
+	defer lc1.Close()
+	lc2 := v3.NewLocalCache(time.Minute)
+	defer lc2.Close()
+	c1, err := NewMultiLevelCache(lc1, NewRedisCache(rdb), rdb, MultiLevelCacheWithChannel("invalidation:e2e"))
+	require.NoError(t, err)
+	c2, err := NewMultiLevelCache(lc2, NewRedisCache(rdb), rdb, MultiLevelCacheWithChannel("invalidation:e2e"))
+	require.NoError(t, err)
+	go func() {
+		_ = c2.Subscribe(ctx, rdb)
+	}()
+	// 等订阅生效
+	time.Sleep(time.Millisecond * 200)
+
+	require.NoError(t, c1.Set(ctx, "mlc:e2e", "v1", time.Minute))
+	val, err := c2.Get(ctx, "mlc:e2e")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", val)
+
+	require.NoError(t, c1.Set(ctx, "mlc:e2e", "v2", time.Minute))
+	assert.Eventually(t, func() bool {
+		val, err := c2.Get(ctx, "mlc:e2e")
+		return err == nil && val == "v2"
+	}, time.Second, time.Millisecond*50)
+	require.NoError(t, c1.Delete(ctx, "mlc:e2e"))
+}
+
+func TestRedisCache_e2e_Batch(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	c := NewRedisCache(rdb)
+
+	require.NoError(t, c.MSet(ctx, map[string]any{"batch:k1": "v1", "batch:k2": "v2"}, time.Minute))
+	vals, err := c.MGet(ctx, []string{"batch:k1", "batch:k2", "batch:k3"})
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	assert.Equal(t, map[string]any{"batch:k1": "v1", "batch:k2": "v2"}, vals)
+	require.NoError(t, c.MDelete(ctx, []string{"batch:k1", "batch:k2"}))
+}
+
+func TestNamespacedCache_e2e_FlushNamespace(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	a := NewNamespacedCache(NewRedisCache(rdb), "ns-e2e-a", NamespacedCacheWithRedisQuota(rdb))
+	b := NewNamespacedCache(NewRedisCache(rdb), "ns-e2e-b", NamespacedCacheWithRedisQuota(rdb))
+	for i := 0; i < 100; i++ {
+		require.NoError(t, a.Set(ctx, fmt.Sprintf("k%d", i), "v", time.Minute))
+	}
+	require.NoError(t, b.Set(ctx, "k1", "v", time.Minute))
+
+	require.NoError(t, a.FlushNamespace(ctx))
+	_, err := a.Get(ctx, "k1")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	_, err = b.Get(ctx, "k1")
+	require.NoError(t, err)
+	require.NoError(t, b.FlushNamespace(ctx))
+}
+
+func TestNamespacedCache_e2e_Quota(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	// 两个实例共享同一份配额
+	a := NewNamespacedCache(NewRedisCache(rdb), "ns-e2e-quota", NamespacedCacheWithMaxKeys(2),
+		NamespacedCacheWithRedisQuota(rdb))
+	b := NewNamespacedCache(NewRedisCache(rdb), "ns-e2e-quota", NamespacedCacheWithMaxKeys(2),
+		NamespacedCacheWithRedisQuota(rdb))
+	defer func() {
+		require.NoError(t, a.FlushNamespace(ctx))
+	}()
+
+	require.NoError(t, a.Set(ctx, "k1", "v1", time.Minute))
+	require.NoError(t, b.Set(ctx, "k2", "v2", time.Millisecond*100))
+	assert.ErrorIs(t, a.Set(ctx, "k3", "v3", time.Minute), ErrNamespaceQuotaExceeded)
+	usage, err := b.Usage(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, NamespaceUsage{Keys: 2, Memory: 34}, usage)
+
+	// k2 过期之后释放配额
+	time.Sleep(time.Millisecond * 200)
+	require.NoError(t, a.Set(ctx, "k3", "v3", time.Minute))
+	require.NoError(t, b.Delete(ctx, "k3"))
+	usage, err = a.Usage(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, NamespaceUsage{Keys: 1, Memory: 17}, usage)
+}
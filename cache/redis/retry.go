@@ -0,0 +1,83 @@
+package cache
+
+import "time"
+
+var (
+	_ RetryStrategy = (*FixedIntervalRetry)(nil)
+	_ RetryStrategy = (*ExponentialBackoffRetry)(nil)
+	_ RetryStrategy = (*MaxAttemptsRetry)(nil)
+)
+
+// RetryStrategy 控制抢锁失败之后的重试。
+// 实现一般是有状态的，每次 Lock 都要传一个新的
+type RetryStrategy interface {
+	// Next 返回下一次重试之前要等多久，false 表示不要再重试了
+	Next() (time.Duration, bool)
+}
+
+// FixedIntervalRetry 固定间隔，一直重试，一般和 MaxAttemptsRetry 或者 ctx 的超时一起用
+type FixedIntervalRetry struct {
+	Interval time.Duration
+}
+
+func NewFixedIntervalRetry(interval time.Duration) *FixedIntervalRetry {
+	return &FixedIntervalRetry{Interval: interval}
+}
+
+func (r *FixedIntervalRetry) Next() (time.Duration, bool) {
+	return r.Interval, true
+}
+
+// minBackoffInterval 是 ExponentialBackoffRetry 最小的重试间隔，
+// 初始间隔是 0 的时候翻倍之后还是 0，会变成不停地重试
+const minBackoffInterval = time.Millisecond
+
+// ExponentialBackoffRetry 每次重试间隔翻倍，最多到 maxInterval
+type ExponentialBackoffRetry struct {
+	next        time.Duration
+	maxInterval time.Duration
+}
+
+// NewExponentialBackoffRetry initial 小于 minBackoffInterval 的时候按照 minBackoffInterval 计算
+func NewExponentialBackoffRetry(initial, maxInterval time.Duration) *ExponentialBackoffRetry {
+	if initial < minBackoffInterval {
+		initial = minBackoffInterval
+	}
+	return &ExponentialBackoffRetry{
+		next:        initial,
+		maxInterval: maxInterval,
+	}
+}
+
+func (r *ExponentialBackoffRetry) Next() (time.Duration, bool) {
+	interval := r.next
+	if r.next < r.maxInterval {
+		r.next *= 2
+		if r.next > r.maxInterval {
+			r.next = r.maxInterval
+		}
+	}
+	return interval, true
+}
+
+// MaxAttemptsRetry 限制 strategy 最多重试 maxAttempts 次
+type MaxAttemptsRetry struct {
+	strategy    RetryStrategy
+	maxAttempts int
+	cnt         int
+}
+
+func NewMaxAttemptsRetry(strategy RetryStrategy, maxAttempts int) *MaxAttemptsRetry {
+	return &MaxAttemptsRetry{
+		strategy:    strategy,
+		maxAttempts: maxAttempts,
+	}
+}
+
+func (r *MaxAttemptsRetry) Next() (time.Duration, bool) {
+	if r.cnt >= r.maxAttempts {
+		return 0, false
+	}
+	r.cnt++
+	return r.strategy.Next()
+}
@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryStrategy(t *testing.T) {
+	testCases := []struct {
+		name     string
+		strategy RetryStrategy
+		want     []time.Duration
+	}{
+		{
+			name:     "fixed interval",
+			strategy: NewMaxAttemptsRetry(NewFixedIntervalRetry(time.Second), 3),
+			want:     []time.Duration{time.Second, time.Second, time.Second},
+		},
+		{
+			name:     "exponential backoff",
+			strategy: NewMaxAttemptsRetry(NewExponentialBackoffRetry(time.Second, 5*time.Second), 5),
+			want:     []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second},
+		},
+		{
+			// 初始间隔是 0 的时候不能一直是 0
+			name:     "exponential backoff from zero",
+			strategy: NewMaxAttemptsRetry(NewExponentialBackoffRetry(0, 5*time.Millisecond), 4),
+			want:     []time.Duration{time.Millisecond, 2 * time.Millisecond, 4 * time.Millisecond, 5 * time.Millisecond},
+		},
+		{
+			name:     "no retry",
+			strategy: NewMaxAttemptsRetry(NewFixedIntervalRetry(time.Second), 0),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []time.Duration
+			for {
+				interval, ok := tc.strategy.Next()
+				if !ok {
+					break
+				}
+				got = append(got, interval)
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"context"
+	_ "embed"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	//go:embed lua/rlock.lua
+	luaRLock string
+	//go:embed lua/wlock.lua
+	luaWLock string
+)
+
+// RWLock 基于 redis 的读写锁，可以有多个读者，或者一个写者。
+// 读者保存在 {key}:r 这个 zset 里面，和 Semaphore 一样按照租约过期；
+// 写者是 {key}:w，和 Lock 一样。用 hash tag 保证在集群里面落在同一个 slot。
+// 没有做写优先，读者一直不断的时候写者可能会等很久
+type RWLock struct {
+	client   redis.Cmdable
+	readKey  string
+	writeKey string
+	ttl      time.Duration
+}
+
+func NewRWLock(client redis.Cmdable, key string, ttl time.Duration) *RWLock {
+	return &RWLock{
+		client:   client,
+		readKey:  "{" + key + "}:r",
+		writeKey: "{" + key + "}:w",
+		ttl:      ttl,
+	}
+}
+
+// TryRLock 只尝试一次，有写者的时候返回 ErrFailedToPreemptLock
+func (l *RWLock) TryRLock(ctx context.Context) (*Lease, error) {
+	return l.RLock(ctx, NewMaxAttemptsRetry(NewFixedIntervalRetry(0), 0))
+}
+
+// RLock 有写者的时候按照 retry 重试
+func (l *RWLock) RLock(ctx context.Context, retry RetryStrategy) (*Lease, error) {
+	token, err := l.acquire(ctx, luaRLock, retry)
+	if err != nil {
+		return nil, err
+	}
+	return newLease(l.client, l.readKey, token, l.ttl, luaLeaseRelease, luaLeaseRefresh), nil
+}
+
+// TryLock 只尝试一次，有读者或者写者的时候返回 ErrFailedToPreemptLock
+func (l *RWLock) TryLock(ctx context.Context) (*Lease, error) {
+	return l.Lock(ctx, NewMaxAttemptsRetry(NewFixedIntervalRetry(0), 0))
+}
+
+// Lock 有读者或者写者的时候按照 retry 重试
+func (l *RWLock) Lock(ctx context.Context, retry RetryStrategy) (*Lease, error) {
+	token, err := l.acquire(ctx, luaWLock, retry)
+	if err != nil {
+		return nil, err
+	}
+	return newLease(l.client, l.writeKey, token, l.ttl, luaUnlock, luaRefresh), nil
+}
+
+func (l *RWLock) acquire(ctx context.Context, script string, retry RetryStrategy) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	err = acquire(ctx, retry, func() (bool, error) {
+		res, err := l.client.Eval(ctx, script, []string{l.readKey, l.writeKey},
+			token, l.ttl.Milliseconds()).Int64()
+		return res == 1, err
+	})
+	return token, err
+}
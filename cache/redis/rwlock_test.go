@@ -0,0 +1,86 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/luxpo/time-go2nd/cache/redis/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRWLock(t *testing.T) {
+	keys := []string{"{rw}:r", "{rw}:w"}
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) redis.Cmdable
+		lock func(l *RWLock) (*Lease, error)
+
+		wantKey           string
+		wantReleaseScript string
+		wantErr           error
+	}{
+		{
+			name: "read locked",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				res := redis.NewCmd(context.Background())
+				res.SetVal(int64(1))
+				cmd.EXPECT().Eval(gomock.Any(), luaRLock, keys, gomock.Any(), int64(60000)).Return(res)
+				return cmd
+			},
+			lock: func(l *RWLock) (*Lease, error) {
+				return l.TryRLock(context.Background())
+			},
+			wantKey:           "{rw}:r",
+			wantReleaseScript: luaLeaseRelease,
+		},
+		{
+			name: "write locked",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				res := redis.NewCmd(context.Background())
+				res.SetVal(int64(1))
+				cmd.EXPECT().Eval(gomock.Any(), luaWLock, keys, gomock.Any(), int64(60000)).Return(res)
+				return cmd
+			},
+			lock: func(l *RWLock) (*Lease, error) {
+				return l.TryLock(context.Background())
+			},
+			wantKey:           "{rw}:w",
+			wantReleaseScript: luaUnlock,
+		},
+		{
+			name: "write lock held by others",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				res := redis.NewCmd(context.Background())
+				res.SetVal(int64(0))
+				cmd.EXPECT().Eval(gomock.Any(), luaRLock, keys, gomock.Any(), int64(60000)).Return(res).Times(3)
+				return cmd
+			},
+			lock: func(l *RWLock) (*Lease, error) {
+				return l.RLock(context.Background(), NewMaxAttemptsRetry(NewFixedIntervalRetry(time.Millisecond), 2))
+			},
+			wantErr: ErrFailedToPreemptLock,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			l, err := tc.lock(NewRWLock(tc.mock(ctrl), "rw", time.Minute))
+			assert.ErrorIs(t, err, tc.wantErr)
+			if err != nil {
+				return
+			}
+			require.NotEmpty(t, l.token)
+			assert.Equal(t, tc.wantKey, l.key)
+			assert.Equal(t, tc.wantReleaseScript, l.releaseScript)
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"context"
+	_ "embed"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+//go:embed lua/semaphore_acquire.lua
+var luaSemaphoreAcquire string
+
+// Semaphore 基于 redis 的计数信号量，同一时间最多 limit 个持有者。
+// 持有者的 token 保存在一个 zset 里面，score 是租约到期的时间，
+// 每次获取的时候先清理掉过期的持有者
+type Semaphore struct {
+	client redis.Cmdable
+	key    string
+	limit  int
+	ttl    time.Duration
+}
+
+// NewSemaphore ttl 是每个租约的时长，需要通过 Lease.Refresh 或者 Lease.AutoRefresh 续约
+func NewSemaphore(client redis.Cmdable, key string, limit int, ttl time.Duration) *Semaphore {
+	return &Semaphore{
+		client: client,
+		key:    key,
+		limit:  limit,
+		ttl:    ttl,
+	}
+}
+
+// TryAcquire 只尝试一次，名额满了的时候返回 ErrFailedToPreemptLock
+func (s *Semaphore) TryAcquire(ctx context.Context) (*Lease, error) {
+	return s.Acquire(ctx, NewMaxAttemptsRetry(NewFixedIntervalRetry(0), 0))
+}
+
+// Acquire 名额满了的时候按照 retry 重试，直到 retry 放弃或者 ctx 过期
+func (s *Semaphore) Acquire(ctx context.Context, retry RetryStrategy) (*Lease, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	err = acquire(ctx, retry, func() (bool, error) {
+		res, err := s.client.Eval(ctx, luaSemaphoreAcquire, []string{s.key},
+			token, s.limit, s.ttl.Milliseconds()).Int64()
+		return res == 1, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newLease(s.client, s.key, token, s.ttl, luaLeaseRelease, luaLeaseRefresh), nil
+}
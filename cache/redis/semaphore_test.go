@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/luxpo/time-go2nd/cache/redis/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSemaphore_Acquire(t *testing.T) {
+	testCases := []struct {
+		name  string
+		mock  func(ctrl *gomock.Controller) redis.Cmdable
+		retry RetryStrategy
+
+		wantErr error
+	}{
+		{
+			name: "acquired",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				res := redis.NewCmd(context.Background())
+				res.SetVal(int64(1))
+				cmd.EXPECT().Eval(gomock.Any(), luaSemaphoreAcquire, []string{"sem"},
+					gomock.Any(), 3, int64(60000)).Return(res)
+				return cmd
+			},
+			retry: NewMaxAttemptsRetry(NewFixedIntervalRetry(time.Millisecond), 3),
+		},
+		{
+			name: "acquired after retry",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				full := redis.NewCmd(context.Background())
+				full.SetVal(int64(0))
+				res := redis.NewCmd(context.Background())
+				res.SetVal(int64(1))
+				gomock.InOrder(
+					cmd.EXPECT().Eval(gomock.Any(), luaSemaphoreAcquire, []string{"sem"},
+						gomock.Any(), 3, int64(60000)).Return(full),
+					cmd.EXPECT().Eval(gomock.Any(), luaSemaphoreAcquire, []string{"sem"},
+						gomock.Any(), 3, int64(60000)).Return(res),
+				)
+				return cmd
+			},
+			retry: NewMaxAttemptsRetry(NewFixedIntervalRetry(time.Millisecond), 3),
+		},
+		{
+			name: "full",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				full := redis.NewCmd(context.Background())
+				full.SetVal(int64(0))
+				cmd.EXPECT().Eval(gomock.Any(), luaSemaphoreAcquire, []string{"sem"},
+					gomock.Any(), 3, int64(60000)).Return(full).Times(2)
+				return cmd
+			},
+			retry:   NewMaxAttemptsRetry(NewFixedIntervalRetry(time.Millisecond), 1),
+			wantErr: ErrFailedToPreemptLock,
+		},
+		{
+			name: "redis error",
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				res := redis.NewCmd(context.Background())
+				res.SetErr(errors.New("mock error"))
+				cmd.EXPECT().Eval(gomock.Any(), luaSemaphoreAcquire, []string{"sem"},
+					gomock.Any(), 3, int64(60000)).Return(res)
+				return cmd
+			},
+			retry:   NewMaxAttemptsRetry(NewFixedIntervalRetry(time.Millisecond), 3),
+			wantErr: errors.New("mock error"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			s := NewSemaphore(tc.mock(ctrl), "sem", 3, time.Minute)
+			l, err := s.Acquire(context.Background(), tc.retry)
+			if tc.wantErr != nil {
+				assert.EqualError(t, err, tc.wantErr.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "sem", l.key)
+			assert.NotEmpty(t, l.token)
+		})
+	}
+}
+
+func TestLease(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	cmd := mocks.NewMockCmdable(ctrl)
+	ok := redis.NewCmd(context.Background())
+	ok.SetVal(int64(1))
+	expired := redis.NewCmd(context.Background())
+	expired.SetVal(int64(0))
+	gomock.InOrder(
+		cmd.EXPECT().Eval(gomock.Any(), luaLeaseRefresh, []string{"sem"}, "token", int64(60000)).Return(ok),
+		cmd.EXPECT().Eval(gomock.Any(), luaLeaseRefresh, []string{"sem"}, "token", int64(60000)).Return(expired),
+		cmd.EXPECT().Eval(gomock.Any(), luaLeaseRelease, []string{"sem"}, "token").Return(expired),
+	)
+
+	l := newLease(cmd, "sem", "token", time.Minute, luaLeaseRelease, luaLeaseRefresh)
+	require.NoError(t, l.Refresh(context.Background()))
+	// 续约失败之后 AutoRefresh 退出
+	err := l.AutoRefresh(time.Millisecond, time.Second)
+	assert.ErrorIs(t, err, ErrLockNotHold)
+	assert.ErrorIs(t, l.Release(context.Background()), ErrLockNotHold)
+}
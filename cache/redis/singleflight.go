@@ -0,0 +1,176 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	_ Cache = (*SingleflightCache)(nil)
+)
+
+// unlockTimeout 释放锁使用单独的超时时间，
+// 回源超时之后 ctx 已经过期了，用它释放锁只能等锁自己过期
+const unlockTimeout = time.Second
+
+// SingleflightCache 在 ReadThroughCache 的基础上防止缓存击穿：
+// 热点 key 过期的时候，同一个 key 的并发回源只会有一个真正执行，其它的等结果。
+// 每个调用方的 ctx 只控制自己等多久，不会取消共享的那一次回源。
+type SingleflightCache struct {
+	*ReadThroughCache
+	g singleflight.Group
+
+	loadTimeout time.Duration
+
+	// 下面是跨进程的版本，client 为 nil 的时候只在进程内合并
+	client         redis.Cmdable
+	lockPrefix     string
+	lockExpiration time.Duration
+	retryInterval  time.Duration
+}
+
+type SingleflightCacheOption func(c *SingleflightCache)
+
+func NewSingleflightCache(c Cache, loadFunc LoadFunc, expiration time.Duration,
+	opts ...SingleflightCacheOption) *SingleflightCache {
+	sc := &SingleflightCache{
+		ReadThroughCache: NewReadThroughCache(c, loadFunc, expiration),
+		lockPrefix:       "cache:lock:",
+		lockExpiration:   time.Second * 10,
+		retryInterval:    time.Millisecond * 50,
+	}
+	for _, opt := range opts {
+		opt(sc)
+	}
+	return sc
+}
+
+// SingleflightCacheWithLoadTimeout 共享的回源不受调用方 ctx 的控制，用这个限制它的最长时间
+func SingleflightCacheWithLoadTimeout(timeout time.Duration) SingleflightCacheOption {
+	return func(c *SingleflightCache) {
+		c.loadTimeout = timeout
+	}
+}
+
+// SingleflightCacheWithRedisLock 回源之前先用 SETNX 抢一个分布式锁，
+// 没抢到的实例等别人把缓存写好之后直接读缓存。
+// 等待超过 lockExpiration 还没有结果的时候，自己回源。
+func SingleflightCacheWithRedisLock(client redis.Cmdable, lockExpiration, retryInterval time.Duration) SingleflightCacheOption {
+	return func(c *SingleflightCache) {
+		c.client = client
+		c.lockExpiration = lockExpiration
+		c.retryInterval = retryInterval
+	}
+}
+
+// SingleflightCacheWithNegativeCache 参考 ReadThroughCacheWithNegativeCache
+func SingleflightCacheWithNegativeCache(expiration time.Duration) SingleflightCacheOption {
+	return func(c *SingleflightCache) {
+		c.negativeExpiration = expiration
+	}
+}
+
+func (s *SingleflightCache) Get(ctx context.Context, key string) (any, error) {
+	val, err := s.get(ctx, key)
+	if err == nil || !errors.Is(err, ErrKeyNotFound) {
+		return val, err
+	}
+
+	ch := s.g.DoChan(key, func() (any, error) {
+		loadCtx := context.Context(detachedContext{parent: ctx})
+		if s.loadTimeout > 0 {
+			var cancel context.CancelFunc
+			loadCtx, cancel = context.WithTimeout(loadCtx, s.loadTimeout)
+			defer cancel()
+		}
+		if s.client == nil {
+			return s.load(loadCtx, key)
+		}
+		return s.loadWithLock(loadCtx, key)
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		return res.Val, res.Err
+	}
+}
+
+func (s *SingleflightCache) loadWithLock(ctx context.Context, key string) (any, error) {
+	lockKey := s.lockPrefix + key
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(s.lockExpiration)
+	for {
+		ok, err := s.client.SetNX(ctx, lockKey, token, s.lockExpiration).Result()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			defer func() {
+				unlockCtx, cancel := context.WithTimeout(detachedContext{parent: ctx}, unlockTimeout)
+				defer cancel()
+				_ = s.client.Eval(unlockCtx, luaUnlock, []string{lockKey}, token).Err()
+			}()
+			// 拿到锁之后再检查一遍，可能别的实例刚刚写好
+			val, err := s.get(ctx, key)
+			if err == nil || !errors.Is(err, ErrKeyNotFound) {
+				return val, err
+			}
+			return s.load(ctx, key)
+		}
+
+		// 别的实例在回源，等它写缓存
+		timer := time.NewTimer(s.retryInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		val, err := s.get(ctx, key)
+		if err == nil || !errors.Is(err, ErrKeyNotFound) {
+			return val, err
+		}
+		if time.Now().After(deadline) {
+			return s.load(ctx, key)
+		}
+	}
+}
+
+func newToken() (string, error) {
+	bs := make([]byte, 16)
+	if _, err := rand.Read(bs); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bs), nil
+}
+
+// detachedContext 保留 parent 里面的值，但是不会被 parent 取消
+type detachedContext struct {
+	parent context.Context
+}
+
+func (d detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (d detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (d detachedContext) Err() error {
+	return nil
+}
+
+func (d detachedContext) Value(key any) any {
+	return d.parent.Value(key)
+}
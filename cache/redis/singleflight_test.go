@@ -0,0 +1,147 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	v3 "github.com/luxpo/time-go2nd/cache/local/v3"
+	"github.com/luxpo/time-go2nd/cache/redis/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSingleflightCache_Get(t *testing.T) {
+	lc := v3.NewLocalCache(time.Minute)
+	defer lc.Close()
+	var loads atomic.Int32
+	start := make(chan struct{})
+	c := NewSingleflightCache(lc, func(ctx context.Context, key string) (any, error) {
+		loads.Add(1)
+		<-start
+		return "v1", nil
+	}, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			val, err := c.Get(context.Background(), "k1")
+			assert.NoError(t, err)
+			assert.Equal(t, "v1", val)
+		}()
+	}
+
+	// 调用方取消不影响共享的回源
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error)
+	go func() {
+		_, err := c.Get(ctx, "k1")
+		cancelled <- err
+	}()
+	cancel()
+	assert.ErrorIs(t, <-cancelled, context.Canceled)
+
+	close(start)
+	wg.Wait()
+	assert.Equal(t, int32(1), loads.Load())
+	val, err := lc.Get(context.Background(), "k1")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", val)
+}
+
+func TestSingleflightCache_RedisLock(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller, lc *v3.LocalCache) redis.Cmdable
+
+		wantVal   any
+		wantLoads int32
+	}{
+		{
+			name: "lock acquired",
+			mock: func(ctrl *gomock.Controller, lc *v3.LocalCache) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				res := redis.NewBoolCmd(context.Background())
+				res.SetVal(true)
+				cmd.EXPECT().SetNX(gomock.Any(), "cache:lock:k1", gomock.Any(), time.Second).Return(res)
+				unlock := redis.NewCmd(context.Background())
+				unlock.SetVal(int64(1))
+				cmd.EXPECT().Eval(gomock.Any(), luaUnlock, []string{"cache:lock:k1"}, gomock.Any()).Return(unlock)
+				return cmd
+			},
+			wantVal:   "loaded",
+			wantLoads: 1,
+		},
+		{
+			name: "other instance loaded",
+			mock: func(ctrl *gomock.Controller, lc *v3.LocalCache) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				res := redis.NewBoolCmd(context.Background())
+				res.SetVal(false)
+				cmd.EXPECT().SetNX(gomock.Any(), "cache:lock:k1", gomock.Any(), time.Second).
+					DoAndReturn(func(ctx context.Context, key string, val any, expiration time.Duration) *redis.BoolCmd {
+						// 模拟别的实例写好了缓存
+						_ = lc.Set(ctx, "k1", "other", time.Minute)
+						return res
+					})
+				return cmd
+			},
+			wantVal: "other",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			lc := v3.NewLocalCache(time.Minute)
+			defer lc.Close()
+			var loads atomic.Int32
+			c := NewSingleflightCache(lc, func(ctx context.Context, key string) (any, error) {
+				loads.Add(1)
+				return "loaded", nil
+			}, time.Minute, SingleflightCacheWithRedisLock(tc.mock(ctrl, lc), time.Second, time.Millisecond))
+
+			val, err := c.Get(context.Background(), "k1")
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantVal, val)
+			assert.Equal(t, tc.wantLoads, loads.Load())
+		})
+	}
+}
+
+func TestSingleflightCache_UnlockAfterLoadTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	lc := v3.NewLocalCache(time.Minute)
+	defer lc.Close()
+
+	cmd := mocks.NewMockCmdable(ctrl)
+	res := redis.NewBoolCmd(context.Background())
+	res.SetVal(true)
+	cmd.EXPECT().SetNX(gomock.Any(), "cache:lock:k1", gomock.Any(), time.Second).Return(res)
+	cmd.EXPECT().Eval(gomock.Any(), luaUnlock, []string{"cache:lock:k1"}, gomock.Any()).
+		DoAndReturn(func(ctx context.Context, script string, keys []string, args ...any) *redis.Cmd {
+			// 回源超时了也要能释放锁
+			assert.NoError(t, ctx.Err())
+			unlock := redis.NewCmd(ctx)
+			unlock.SetVal(int64(1))
+			return unlock
+		})
+
+	c := NewSingleflightCache(lc, func(ctx context.Context, key string) (any, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, time.Minute,
+		SingleflightCacheWithRedisLock(cmd, time.Second, time.Millisecond),
+		SingleflightCacheWithLoadTimeout(time.Millisecond*10))
+
+	_, err := c.Get(context.Background(), "k1")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
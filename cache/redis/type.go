@@ -11,3 +11,14 @@ type Cache interface {
 	Delete(ctx context.Context, key string) error
 	LoadAndDelete(ctx context.Context, key string) (any, error)
 }
+
+// BatchCache 批量操作，RedisCache 和 v3.LocalCache 都实现了这个接口。
+// MGet 返回的 map 里面只有存在的 key，不存在的 key 每个对应一个 ErrKeyNotFound，
+// 用 errors.Join 合并之后返回；整个请求失败的时候返回的 map 是 nil。
+// MSet 和 MDelete 部分失败的时候，也把每个 key 的错误合并起来返回
+type BatchCache interface {
+	Cache
+	MGet(ctx context.Context, keys []string) (map[string]any, error)
+	MSet(ctx context.Context, vals map[string]any, expiration time.Duration) error
+	MDelete(ctx context.Context, keys []string) error
+}
@@ -0,0 +1,245 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	_ Cache = (*WriteBackCache)(nil)
+
+	// ErrWriteBackQueueFull 待写回的 key 太多了，数据源跟不上
+	ErrWriteBackQueueFull = errors.New("cache: 写回队列已满")
+	errWriteBackClosed    = errors.New("cache: 写回缓存已经关闭")
+)
+
+// BatchStoreFunc 批量把数据写到数据源
+type BatchStoreFunc func(ctx context.Context, vals map[string]any) error
+
+// WriteBackCache 只写缓存，脏数据在后台批量写回数据源。
+// 同一个 key 多次写入只会写回最后一次的值，适合写多读少的场景，比如计数器。
+// Delete 只删除缓存，不会取消已经在排队的写回。
+type WriteBackCache struct {
+	Cache
+	storeFunc BatchStoreFunc
+
+	batchSize     int
+	flushInterval time.Duration
+	maxPending    int
+	maxRetries    int
+	retryInterval time.Duration
+
+	mu    sync.Mutex
+	dirty map[string]any
+	// reserved 是已经通过了 maxPending 检查、还在写缓存的 Set 的数量
+	reserved int
+	closed   bool
+
+	flushSignal chan struct{}
+	closeCh     chan struct{}
+	done        chan struct{}
+	closeOnce   sync.Once
+	closeErr    error
+}
+
+type WriteBackCacheOption func(c *WriteBackCache)
+
+func NewWriteBackCache(c Cache, storeFunc BatchStoreFunc, opts ...WriteBackCacheOption) *WriteBackCache {
+	wb := &WriteBackCache{
+		Cache:         c,
+		storeFunc:     storeFunc,
+		batchSize:     100,
+		flushInterval: time.Second,
+		maxPending:    10000,
+		maxRetries:    3,
+		retryInterval: time.Millisecond * 100,
+		dirty:         make(map[string]any),
+		flushSignal:   make(chan struct{}, 1),
+		closeCh:       make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(wb)
+	}
+	go wb.loop()
+	return wb
+}
+
+// WriteBackCacheWithBatchSize 攒够 size 个脏 key 就立刻写回一次，
+// size 小于 1 的时候使用默认值
+func WriteBackCacheWithBatchSize(size int) WriteBackCacheOption {
+	return func(c *WriteBackCache) {
+		if size >= 1 {
+			c.batchSize = size
+		}
+	}
+}
+
+// WriteBackCacheWithFlushInterval 没有攒够一批的时候，最多等 interval 就写回，
+// interval 小于等于 0 的时候使用默认值
+func WriteBackCacheWithFlushInterval(interval time.Duration) WriteBackCacheOption {
+	return func(c *WriteBackCache) {
+		if interval > 0 {
+			c.flushInterval = interval
+		}
+	}
+}
+
+// WriteBackCacheWithMaxPending 待写回的 key 的上限，超过之后 Set 返回 ErrWriteBackQueueFull，
+// maxPending 小于 1 的时候使用默认值
+func WriteBackCacheWithMaxPending(maxPending int) WriteBackCacheOption {
+	return func(c *WriteBackCache) {
+		if maxPending >= 1 {
+			c.maxPending = maxPending
+		}
+	}
+}
+
+// WriteBackCacheWithRetry 每一批最多重试 maxRetries 次，
+// 还是失败的话放回队列，等下一次写回。
+// maxRetries 为 0 表示不重试，小于 0 的时候使用默认值
+func WriteBackCacheWithRetry(maxRetries int, interval time.Duration) WriteBackCacheOption {
+	return func(c *WriteBackCache) {
+		if maxRetries >= 0 {
+			c.maxRetries = maxRetries
+		}
+		c.retryInterval = interval
+	}
+}
+
+func (w *WriteBackCache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return errWriteBackClosed
+	}
+	// 检查和占位要在同一个临界区里面，否则并发的 Set 都能通过检查
+	_, ok := w.dirty[key]
+	if !ok && len(w.dirty)+w.reserved >= w.maxPending {
+		w.mu.Unlock()
+		w.signal()
+		return ErrWriteBackQueueFull
+	}
+	w.reserved++
+	w.mu.Unlock()
+
+	err := w.Cache.Set(ctx, key, val, expiration)
+	w.mu.Lock()
+	w.reserved--
+	if err != nil {
+		w.mu.Unlock()
+		return err
+	}
+	if w.closed {
+		// Close 已经把脏数据写完了，这个值没办法再写回
+		w.mu.Unlock()
+		return errWriteBackClosed
+	}
+	w.dirty[key] = val
+	full := len(w.dirty) >= w.batchSize
+	w.mu.Unlock()
+	if full {
+		w.signal()
+	}
+	return nil
+}
+
+// Close 停止后台写回，并且把剩下的脏数据全部写回去。
+// 某一批写回失败不会影响后面的批次，返回的是所有失败批次的错误
+func (w *WriteBackCache) Close() error {
+	w.closeOnce.Do(func() {
+		w.mu.Lock()
+		w.closed = true
+		w.mu.Unlock()
+		close(w.closeCh)
+		<-w.done
+		var errs []error
+		for {
+			batch := w.takeBatch()
+			if len(batch) == 0 {
+				break
+			}
+			if err := w.store(batch); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		w.closeErr = errors.Join(errs...)
+	})
+	return w.closeErr
+}
+
+func (w *WriteBackCache) signal() {
+	select {
+	case w.flushSignal <- struct{}{}:
+	default:
+	}
+}
+
+func (w *WriteBackCache) loop() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-w.flushSignal:
+		case <-w.closeCh:
+			return
+		}
+		w.flush()
+	}
+}
+
+// flush 把当前所有的脏数据按批写回
+func (w *WriteBackCache) flush() {
+	for {
+		batch := w.takeBatch()
+		if len(batch) == 0 {
+			return
+		}
+		if err := w.store(batch); err != nil {
+			w.putBack(batch)
+			return
+		}
+	}
+}
+
+func (w *WriteBackCache) takeBatch() map[string]any {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	batch := make(map[string]any, w.batchSize)
+	for k, v := range w.dirty {
+		if len(batch) >= w.batchSize {
+			break
+		}
+		batch[k] = v
+		delete(w.dirty, k)
+	}
+	return batch
+}
+
+// putBack 写回失败，放回队列。期间已经有新值的 key 以新值为准
+func (w *WriteBackCache) putBack(batch map[string]any) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for k, v := range batch {
+		if _, ok := w.dirty[k]; !ok {
+			w.dirty[k] = v
+		}
+	}
+}
+
+func (w *WriteBackCache) store(batch map[string]any) error {
+	var err error
+	for i := 0; i <= w.maxRetries; i++ {
+		if i > 0 {
+			time.Sleep(w.retryInterval)
+		}
+		if err = w.storeFunc(context.Background(), batch); err == nil {
+			return nil
+		}
+	}
+	return err
+}
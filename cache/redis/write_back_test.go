@@ -0,0 +1,197 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	v3 "github.com/luxpo/time-go2nd/cache/local/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockStore struct {
+	mu      sync.Mutex
+	data    map[string]any
+	batches int
+	// failures 前几次写入失败
+	failures int
+}
+
+func (m *mockStore) store(ctx context.Context, vals map[string]any) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.failures > 0 {
+		m.failures--
+		return errors.New("mock error")
+	}
+	m.batches++
+	for k, v := range vals {
+		m.data[k] = v
+	}
+	return nil
+}
+
+func (m *mockStore) get(key string) (any, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.data[key]
+	return v, ok
+}
+
+func TestWriteBackCache_Flush(t *testing.T) {
+	lc := v3.NewLocalCache(time.Minute)
+	defer lc.Close()
+	store := &mockStore{data: map[string]any{}, failures: 1}
+	c := NewWriteBackCache(lc, store.store,
+		WriteBackCacheWithBatchSize(2),
+		WriteBackCacheWithFlushInterval(time.Hour),
+		WriteBackCacheWithRetry(1, time.Millisecond))
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, "k1", 1, time.Minute))
+	// 缓存立刻可见
+	val, err := c.Get(ctx, "k1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, val)
+	_, ok := store.get("k1")
+	assert.False(t, ok)
+
+	// 攒够一批，失败一次之后重试成功
+	require.NoError(t, c.Set(ctx, "k1", 2, time.Minute))
+	require.NoError(t, c.Set(ctx, "k2", 1, time.Minute))
+	assert.Eventually(t, func() bool {
+		val, _ := store.get("k1")
+		return val == 2
+	}, time.Second, time.Millisecond*10)
+
+	// Close 的时候写回剩下的
+	require.NoError(t, c.Set(ctx, "k3", 1, time.Minute))
+	require.NoError(t, c.Close())
+	val, ok = store.get("k3")
+	assert.True(t, ok)
+	assert.Equal(t, 1, val)
+	assert.ErrorIs(t, c.Set(ctx, "k4", 1, time.Minute), errWriteBackClosed)
+}
+
+func TestWriteBackCache_QueueFull(t *testing.T) {
+	lc := v3.NewLocalCache(time.Minute)
+	defer lc.Close()
+	block := make(chan struct{})
+	c := NewWriteBackCache(lc, func(ctx context.Context, vals map[string]any) error {
+		<-block
+		return nil
+	}, WriteBackCacheWithMaxPending(2), WriteBackCacheWithFlushInterval(time.Hour))
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, "k1", 1, time.Minute))
+	require.NoError(t, c.Set(ctx, "k2", 1, time.Minute))
+	// 已经在队列里面的 key 可以继续覆盖
+	require.NoError(t, c.Set(ctx, "k2", 2, time.Minute))
+	assert.ErrorIs(t, c.Set(ctx, "k3", 1, time.Minute), ErrWriteBackQueueFull)
+	_, err := c.Get(ctx, "k3")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	close(block)
+	require.NoError(t, c.Close())
+}
+
+func TestWriteBackCache_InvalidOptions(t *testing.T) {
+	lc := v3.NewLocalCache(time.Minute)
+	defer lc.Close()
+	c := NewWriteBackCache(lc, (&mockStore{data: map[string]any{}}).store,
+		WriteBackCacheWithBatchSize(0),
+		WriteBackCacheWithFlushInterval(0),
+		WriteBackCacheWithMaxPending(0),
+		WriteBackCacheWithRetry(-1, time.Millisecond))
+	assert.Equal(t, 100, c.batchSize)
+	assert.Equal(t, time.Second, c.flushInterval)
+	assert.Equal(t, 10000, c.maxPending)
+	assert.Equal(t, 3, c.maxRetries)
+	require.NoError(t, c.Close())
+}
+
+// slowSetCache 的 Set 会阻塞到 release 被关闭
+type slowSetCache struct {
+	Cache
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (c *slowSetCache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	c.entered <- struct{}{}
+	<-c.release
+	return c.Cache.Set(ctx, key, val, expiration)
+}
+
+func TestWriteBackCache_ConcurrentQueueFull(t *testing.T) {
+	lc := v3.NewLocalCache(time.Minute)
+	defer lc.Close()
+	slow := &slowSetCache{
+		Cache:   lc,
+		entered: make(chan struct{}, 10),
+		release: make(chan struct{}),
+	}
+	c := NewWriteBackCache(slow, (&mockStore{data: map[string]any{}}).store,
+		WriteBackCacheWithMaxPending(2), WriteBackCacheWithFlushInterval(time.Hour))
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- c.Set(ctx, string(rune('a'+i)), i, time.Minute)
+		}(i)
+	}
+	// 两个 Set 占满了队列，它们还在写缓存的时候其它的 Set 也要失败
+	<-slow.entered
+	<-slow.entered
+	assert.Eventually(t, func() bool {
+		return len(errs) == 8
+	}, time.Second, time.Millisecond)
+	close(slow.release)
+	wg.Wait()
+	close(errs)
+	var succeeded int
+	for err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		assert.ErrorIs(t, err, ErrWriteBackQueueFull)
+	}
+	assert.Equal(t, 2, succeeded)
+	require.NoError(t, c.Close())
+}
+
+func TestWriteBackCache_CloseError(t *testing.T) {
+	lc := v3.NewLocalCache(time.Minute)
+	defer lc.Close()
+	store := &mockStore{data: map[string]any{}}
+	c := NewWriteBackCache(lc, func(ctx context.Context, vals map[string]any) error {
+		if _, ok := vals["bad"]; ok {
+			return errors.New("mock error")
+		}
+		return store.store(ctx, vals)
+	}, WriteBackCacheWithBatchSize(1),
+		WriteBackCacheWithFlushInterval(time.Hour),
+		WriteBackCacheWithRetry(0, 0))
+	// 不让后台先写回
+	c.mu.Lock()
+	c.dirty["bad"] = 1
+	c.dirty["k1"] = 1
+	c.dirty["k2"] = 2
+	c.mu.Unlock()
+
+	// 失败的批次不影响后面的批次
+	assert.Error(t, c.Close())
+	val, ok := store.get("k1")
+	assert.True(t, ok)
+	assert.Equal(t, 1, val)
+	val, ok = store.get("k2")
+	assert.True(t, ok)
+	assert.Equal(t, 2, val)
+}
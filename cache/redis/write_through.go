@@ -0,0 +1,32 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+var _ Cache = (*WriteThroughCache)(nil)
+
+// StoreFunc 把数据写到数据源
+type StoreFunc func(ctx context.Context, key string, val any) error
+
+// WriteThroughCache 先写数据源，成功之后再写缓存。
+// 写数据源失败的时候不会动缓存
+type WriteThroughCache struct {
+	Cache
+	storeFunc StoreFunc
+}
+
+func NewWriteThroughCache(c Cache, storeFunc StoreFunc) *WriteThroughCache {
+	return &WriteThroughCache{
+		Cache:     c,
+		storeFunc: storeFunc,
+	}
+}
+
+func (w *WriteThroughCache) Set(ctx context.Context, key string, val any, expiration time.Duration) error {
+	if err := w.storeFunc(ctx, key, val); err != nil {
+		return err
+	}
+	return w.Cache.Set(ctx, key, val, expiration)
+}
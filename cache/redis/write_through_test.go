@@ -0,0 +1,34 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v3 "github.com/luxpo/time-go2nd/cache/local/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteThroughCache_Set(t *testing.T) {
+	lc := v3.NewLocalCache(time.Minute)
+	defer lc.Close()
+	store := &mockStore{data: map[string]any{}, failures: 1}
+	c := NewWriteThroughCache(lc, func(ctx context.Context, key string, val any) error {
+		return store.store(ctx, map[string]any{key: val})
+	})
+	ctx := context.Background()
+
+	// 数据源写失败，缓存不会被修改
+	err := c.Set(ctx, "k1", "v1", time.Minute)
+	assert.Error(t, err)
+	_, err = c.Get(ctx, "k1")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+
+	require.NoError(t, c.Set(ctx, "k1", "v1", time.Minute))
+	val, err := c.Get(ctx, "k1")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", val)
+	val, _ = store.get("k1")
+	assert.Equal(t, "v1", val)
+}
@@ -0,0 +1,135 @@
+package stats
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Provider 是能够提供统计数据的缓存
+type Provider interface {
+	Stats() Stats
+}
+
+// PrometheusExporter 把统计数据渲染成 Prometheus 的文本格式，
+// 每个缓存用 cache 这个 label 区分
+type PrometheusExporter struct {
+	namespace string
+
+	mu        sync.RWMutex
+	providers map[string]Provider
+}
+
+func NewPrometheusExporter(namespace string) *PrometheusExporter {
+	return &PrometheusExporter{
+		namespace: namespace,
+		providers: make(map[string]Provider),
+	}
+}
+
+func (e *PrometheusExporter) Register(name string, p Provider) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.providers[name] = p
+}
+
+func (e *PrometheusExporter) Unregister(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.providers, name)
+}
+
+type sample struct {
+	// suffix 用于 summary 的 _sum 和 _count
+	suffix string
+	labels string
+	value  string
+}
+
+type metric struct {
+	name    string
+	help    string
+	typ     string
+	samples []sample
+}
+
+func (e *PrometheusExporter) WriteTo(w io.Writer) (int64, error) {
+	e.mu.RLock()
+	names := make([]string, 0, len(e.providers))
+	for name := range e.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	all := make([]Stats, len(names))
+	for i, name := range names {
+		all[i] = e.providers[name].Stats()
+	}
+	e.mu.RUnlock()
+
+	metrics := []*metric{
+		{name: "hits_total", help: "Number of cache hits.", typ: "counter"},
+		{name: "misses_total", help: "Number of cache misses.", typ: "counter"},
+		{name: "sets_total", help: "Number of cache writes.", typ: "counter"},
+		{name: "deletes_total", help: "Number of cache deletes.", typ: "counter"},
+		{name: "expirations_total", help: "Number of expired entries.", typ: "counter"},
+		{name: "evictions_total", help: "Number of evicted entries by reason.", typ: "counter"},
+		{name: "size", help: "Current number of entries.", typ: "gauge"},
+		{name: "load_duration_seconds", help: "Time spent loading from the source.", typ: "summary"},
+		{name: "load_errors_total", help: "Number of failed loads.", typ: "counter"},
+	}
+	for i, name := range names {
+		s := all[i]
+		l := fmt.Sprintf(`cache="%s"`, escapeLabel(name))
+		metrics[0].add(l, s.Hits)
+		metrics[1].add(l, s.Misses)
+		metrics[2].add(l, s.Sets)
+		metrics[3].add(l, s.Deletes)
+		metrics[4].add(l, s.Expirations)
+		reasons := make([]string, 0, len(s.Evictions))
+		for reason := range s.Evictions {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+		for _, reason := range reasons {
+			metrics[5].add(fmt.Sprintf(`%s,reason="%s"`, l, escapeLabel(reason)), s.Evictions[reason])
+		}
+		metrics[6].add(l, s.Size)
+		metrics[7].samples = append(metrics[7].samples,
+			sample{suffix: "_sum", labels: l, value: fmt.Sprint(s.LoadTotalTime.Seconds())},
+			sample{suffix: "_count", labels: l, value: fmt.Sprint(s.Loads)})
+		metrics[8].add(l, s.LoadErrors)
+	}
+
+	var buf bytes.Buffer
+	for _, m := range metrics {
+		fullName := m.name
+		if e.namespace != "" {
+			fullName = e.namespace + "_" + m.name
+		}
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s %s\n", fullName, m.help, fullName, m.typ)
+		for _, s := range m.samples {
+			fmt.Fprintf(&buf, "%s%s{%s} %s\n", fullName, s.suffix, s.labels, s.value)
+		}
+	}
+	return buf.WriteTo(w)
+}
+
+// ServeHTTP 可以直接挂到 /metrics 上
+func (e *PrometheusExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = e.WriteTo(w)
+}
+
+func (m *metric) add(labels string, val any) {
+	m.samples = append(m.samples, sample{labels: labels, value: fmt.Sprint(val)})
+}
+
+var labelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelReplacer.Replace(s)
+}
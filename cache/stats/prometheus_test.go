@@ -0,0 +1,101 @@
+package stats
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type statsFunc func() Stats
+
+func (f statsFunc) Stats() Stats {
+	return f()
+}
+
+func TestPrometheusExporter_WriteTo(t *testing.T) {
+	var r Recorder
+	r.RecordHit()
+	r.RecordHit()
+	r.RecordMiss()
+	r.RecordSet()
+	r.RecordDelete()
+	r.RecordExpiration()
+	r.RecordEviction("replaced")
+	r.RecordEviction("capacity")
+	r.RecordEviction("capacity")
+	r.RecordLoad(time.Second, nil)
+	r.RecordLoad(time.Second*2, errors.New("mock error"))
+
+	e := NewPrometheusExporter("cache")
+	e.Register("users", statsFunc(func() Stats {
+		s := r.Stats()
+		s.Size = 10
+		return s
+	}))
+	e.Register(`a"b`, statsFunc(func() Stats {
+		return Stats{}
+	}))
+
+	var buf bytes.Buffer
+	_, err := e.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, `# HELP cache_hits_total Number of cache hits.
+# TYPE cache_hits_total counter
+cache_hits_total{cache="a\"b"} 0
+cache_hits_total{cache="users"} 2
+# HELP cache_misses_total Number of cache misses.
+# TYPE cache_misses_total counter
+cache_misses_total{cache="a\"b"} 0
+cache_misses_total{cache="users"} 1
+# HELP cache_sets_total Number of cache writes.
+# TYPE cache_sets_total counter
+cache_sets_total{cache="a\"b"} 0
+cache_sets_total{cache="users"} 1
+# HELP cache_deletes_total Number of cache deletes.
+# TYPE cache_deletes_total counter
+cache_deletes_total{cache="a\"b"} 0
+cache_deletes_total{cache="users"} 1
+# HELP cache_expirations_total Number of expired entries.
+# TYPE cache_expirations_total counter
+cache_expirations_total{cache="a\"b"} 0
+cache_expirations_total{cache="users"} 1
+# HELP cache_evictions_total Number of evicted entries by reason.
+# TYPE cache_evictions_total counter
+cache_evictions_total{cache="users",reason="capacity"} 2
+cache_evictions_total{cache="users",reason="replaced"} 1
+# HELP cache_size Current number of entries.
+# TYPE cache_size gauge
+cache_size{cache="a\"b"} 0
+cache_size{cache="users"} 10
+# HELP cache_load_duration_seconds Time spent loading from the source.
+# TYPE cache_load_duration_seconds summary
+cache_load_duration_seconds_sum{cache="a\"b"} 0
+cache_load_duration_seconds_count{cache="a\"b"} 0
+cache_load_duration_seconds_sum{cache="users"} 3
+cache_load_duration_seconds_count{cache="users"} 2
+# HELP cache_load_errors_total Number of failed loads.
+# TYPE cache_load_errors_total counter
+cache_load_errors_total{cache="a\"b"} 0
+cache_load_errors_total{cache="users"} 1
+`, buf.String())
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, buf.String(), rec.Body.String())
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
+}
+
+func TestStats(t *testing.T) {
+	s := Stats{Hits: 3, Misses: 1, Loads: 2, LoadTotalTime: time.Second}
+	assert.Equal(t, 0.75, s.HitRate())
+	assert.Equal(t, time.Millisecond*500, s.AvgLoadTime())
+	s.Merge(Stats{Hits: 1, Evictions: map[string]uint64{"capacity": 1}})
+	assert.Equal(t, uint64(4), s.Hits)
+	assert.Equal(t, map[string]uint64{"capacity": 1}, s.Evictions)
+	assert.Equal(t, float64(0), Stats{}.HitRate())
+}
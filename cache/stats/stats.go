@@ -0,0 +1,133 @@
+package stats
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats 是某一个时刻的统计数据快照
+type Stats struct {
+	Hits    uint64
+	Misses  uint64
+	Sets    uint64
+	Deletes uint64
+	// Expirations 因为过期被移除的 key 的数量
+	Expirations uint64
+	// Evictions 按照原因统计的其它移除，例如 capacity、explicit、replaced
+	Evictions map[string]uint64
+	// Size 当前 key 的数量，不支持的实现为 0
+	Size int64
+
+	// Loads 回源加载的次数，由 ReadThroughCache 之类的装饰器记录
+	Loads         uint64
+	LoadErrors    uint64
+	LoadTotalTime time.Duration
+}
+
+// HitRate 命中率，没有任何读请求的时候返回 0
+func (s Stats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// AvgLoadTime 平均回源耗时
+func (s Stats) AvgLoadTime() time.Duration {
+	if s.Loads == 0 {
+		return 0
+	}
+	return s.LoadTotalTime / time.Duration(s.Loads)
+}
+
+// Merge 把 o 累加到 s 上，用于汇总分片之类的多个缓存
+func (s *Stats) Merge(o Stats) {
+	s.Hits += o.Hits
+	s.Misses += o.Misses
+	s.Sets += o.Sets
+	s.Deletes += o.Deletes
+	s.Expirations += o.Expirations
+	if s.Evictions == nil {
+		s.Evictions = make(map[string]uint64, len(o.Evictions))
+	}
+	for reason, cnt := range o.Evictions {
+		s.Evictions[reason] += cnt
+	}
+	s.Size += o.Size
+	s.Loads += o.Loads
+	s.LoadErrors += o.LoadErrors
+	s.LoadTotalTime += o.LoadTotalTime
+}
+
+// Recorder 并发安全地记录统计数据，零值可以直接使用
+type Recorder struct {
+	hits          atomic.Uint64
+	misses        atomic.Uint64
+	sets          atomic.Uint64
+	deletes       atomic.Uint64
+	expirations   atomic.Uint64
+	loads         atomic.Uint64
+	loadErrors    atomic.Uint64
+	loadTotalTime atomic.Int64
+
+	// 原因的种类很少，用 sync.Map 保存每个原因的计数器
+	evictions sync.Map
+}
+
+func (r *Recorder) RecordHit() {
+	r.hits.Add(1)
+}
+
+func (r *Recorder) RecordMiss() {
+	r.misses.Add(1)
+}
+
+func (r *Recorder) RecordSet() {
+	r.sets.Add(1)
+}
+
+func (r *Recorder) RecordDelete() {
+	r.deletes.Add(1)
+}
+
+func (r *Recorder) RecordExpiration() {
+	r.expirations.Add(1)
+}
+
+func (r *Recorder) RecordEviction(reason string) {
+	cnt, ok := r.evictions.Load(reason)
+	if !ok {
+		cnt, _ = r.evictions.LoadOrStore(reason, new(atomic.Uint64))
+	}
+	cnt.(*atomic.Uint64).Add(1)
+}
+
+func (r *Recorder) RecordLoad(d time.Duration, err error) {
+	r.loads.Add(1)
+	r.loadTotalTime.Add(int64(d))
+	if err != nil {
+		r.loadErrors.Add(1)
+	}
+}
+
+// Stats 返回当前的统计数据，Size 需要调用方自己填充
+func (r *Recorder) Stats() Stats {
+	s := Stats{
+		Hits:          r.hits.Load(),
+		Misses:        r.misses.Load(),
+		Sets:          r.sets.Load(),
+		Deletes:       r.deletes.Load(),
+		Expirations:   r.expirations.Load(),
+		Evictions:     make(map[string]uint64),
+		Loads:         r.loads.Load(),
+		LoadErrors:    r.loadErrors.Load(),
+		LoadTotalTime: time.Duration(r.loadTotalTime.Load()),
+	}
+	r.evictions.Range(func(key, value any) bool {
+		s.Evictions[key.(string)] = value.(*atomic.Uint64).Load()
+		return true
+	})
+	return s
+}
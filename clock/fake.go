@@ -0,0 +1,116 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+var _ Clock = (*FakeClock)(nil)
+
+// FakeClock 只有调用 Advance 的时候时间才会前进。
+// 和 time.Ticker 一样，ticker 的 channel 只有一个缓冲，来不及消费的 tick 会被丢弃。
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+type fakeWaiter struct {
+	deadline time.Time
+	// period 为 0 表示只触发一次
+	period  time.Duration
+	ch      chan time.Time
+	stopped bool
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{
+		now: now,
+	}
+}
+
+func (f *FakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	return &fakeTicker{f: f, w: f.addWaiter(d, d)}
+}
+
+func (f *FakeClock) After(d time.Duration) <-chan time.Time {
+	return f.addWaiter(d, 0).ch
+}
+
+// Advance 推进时间，并且触发所有到期的 ticker 和 timer
+func (f *FakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+	waiters := f.waiters[:0]
+	for _, w := range f.waiters {
+		if w.stopped {
+			continue
+		}
+		for !w.deadline.After(f.now) {
+			select {
+			case w.ch <- f.now:
+			default:
+			}
+			if w.period == 0 {
+				w.stopped = true
+				break
+			}
+			w.deadline = w.deadline.Add(w.period)
+		}
+		if !w.stopped {
+			waiters = append(waiters, w)
+		}
+	}
+	f.waiters = waiters
+}
+
+// Waiters 返回还没有触发的 ticker 和 timer 的数量，
+// 测试里面可以用来等待后台 goroutine 启动
+func (f *FakeClock) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	cnt := 0
+	for _, w := range f.waiters {
+		if !w.stopped {
+			cnt++
+		}
+	}
+	return cnt
+}
+
+func (f *FakeClock) addWaiter(d, period time.Duration) *fakeWaiter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w := &fakeWaiter{
+		deadline: f.now.Add(d),
+		period:   period,
+		ch:       make(chan time.Time, 1),
+	}
+	f.waiters = append(f.waiters, w)
+	return w
+}
+
+type fakeTicker struct {
+	f *FakeClock
+	w *fakeWaiter
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.w.ch
+}
+
+func (t *fakeTicker) Stop() {
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+	t.w.stopped = true
+}
@@ -0,0 +1,36 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Unix(0, 0)
+	c := NewFakeClock(start)
+	ticker := c.NewTicker(time.Second)
+	after := c.After(time.Second * 2)
+	assert.Equal(t, 2, c.Waiters())
+
+	c.Advance(time.Millisecond * 500)
+	assert.Len(t, ticker.C(), 0)
+	assert.Equal(t, start.Add(time.Millisecond*500), c.Now())
+
+	c.Advance(time.Millisecond * 500)
+	assert.Equal(t, start.Add(time.Second), <-ticker.C())
+	assert.Len(t, after, 0)
+
+	// 来不及消费的 tick 被丢弃
+	c.Advance(time.Second * 3)
+	assert.Equal(t, start.Add(time.Second*4), <-ticker.C())
+	assert.Len(t, ticker.C(), 0)
+	assert.Equal(t, start.Add(time.Second*4), <-after)
+	assert.Equal(t, 1, c.Waiters())
+
+	ticker.Stop()
+	c.Advance(time.Second)
+	assert.Len(t, ticker.C(), 0)
+	assert.Equal(t, 0, c.Waiters())
+}
@@ -6,12 +6,15 @@ import (
 	"net"
 	"sync"
 	"time"
+
+	"github.com/luxpo/time-go2nd/clock"
 )
 
 type Pool struct {
 	maxCnt      int
 	maxIdleTime time.Duration
 	factory     func() (net.Conn, error)
+	clock       clock.Clock
 
 	mu        sync.Mutex
 	idleConns chan *idleConn
@@ -25,6 +28,11 @@ func NewPool(cfg *PoolConfig) (*Pool, error) {
 		return nil, errors.New("init cnt can't be bigger than max cnt")
 	}
 
+	clk := cfg.Clock
+	if clk == nil {
+		clk = clock.New()
+	}
+
 	idleConns := make(chan *idleConn, cfg.MaxIdleCnt)
 	for i := 0; i < cfg.InitCnt; i++ {
 		conn, err := cfg.Factory()
@@ -33,7 +41,7 @@ func NewPool(cfg *PoolConfig) (*Pool, error) {
 		}
 		idleConns <- &idleConn{
 			c:              conn,
-			lastActiveTime: time.Now(),
+			lastActiveTime: clk.Now(),
 		}
 	}
 	pool := &Pool{
@@ -41,6 +49,7 @@ func NewPool(cfg *PoolConfig) (*Pool, error) {
 		maxCnt:      cfg.MaxCnt,
 		maxIdleTime: cfg.MaxIdleTime,
 		factory:     cfg.Factory,
+		clock:       clk,
 	}
 	return pool, nil
 }
@@ -51,6 +60,8 @@ type PoolConfig struct {
 	MaxIdleCnt  int
 	MaxIdleTime time.Duration
 	Factory     func() (net.Conn, error)
+	// Clock 为 nil 的时候使用真实的时钟
+	Clock clock.Clock
 }
 
 type idleConn struct {
@@ -69,7 +80,7 @@ L:
 		case <-ctx.Done():
 			return nil, ctx.Err()
 		case idleConn := <-p.idleConns:
-			if idleConn.lastActiveTime.Add(p.maxIdleTime).Before(time.Now()) {
+			if idleConn.lastActiveTime.Add(p.maxIdleTime).Before(p.clock.Now()) {
 				_ = idleConn.c.Close()
 				continue
 			}
@@ -123,7 +134,7 @@ func (p *Pool) Put(ctx context.Context, c net.Conn) error {
 
 	idleConn := idleConn{
 		c:              c,
-		lastActiveTime: time.Now(),
+		lastActiveTime: p.clock.Now(),
 	}
 	select {
 	case p.idleConns <- &idleConn:
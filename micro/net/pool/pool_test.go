@@ -0,0 +1,49 @@
+package pool
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/luxpo/time-go2nd/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPool_IdleTimeout(t *testing.T) {
+	clk := clock.NewFakeClock(time.Unix(0, 0))
+	created := 0
+	p, err := NewPool(&PoolConfig{
+		InitCnt:     1,
+		MaxCnt:      2,
+		MaxIdleCnt:  2,
+		MaxIdleTime: time.Minute,
+		Factory: func() (net.Conn, error) {
+			created++
+			c, _ := net.Pipe()
+			return c, nil
+		},
+		Clock: clk,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, created)
+	ctx := context.Background()
+
+	// 还没超过空闲时间，复用初始化的连接
+	clk.Advance(time.Second * 59)
+	c, err := p.Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, created)
+	require.NoError(t, p.Put(ctx, c))
+
+	// 超过空闲时间，关掉旧的，创建新的
+	clk.Advance(time.Minute + time.Second)
+	c2, err := p.Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, created)
+	assert.NotEqual(t, c, c2)
+	_, err = c.Write([]byte("x"))
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+}
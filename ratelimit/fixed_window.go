@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"context"
+	_ "embed"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	_ Limiter = (*FixedWindowLimiter)(nil)
+
+	//go:embed lua/fixed_window.lua
+	luaFixedWindow string
+)
+
+// FixedWindowLimiter 固定窗口，每个窗口最多 limit 个请求。
+// 实现最简单，但是窗口交界的地方最多可能通过 2*limit 个请求
+type FixedWindowLimiter struct {
+	client redis.Cmdable
+	limit  int64
+	window time.Duration
+}
+
+// NewFixedWindowLimiter limit 必须大于 0，window 至少一毫秒
+func NewFixedWindowLimiter(client redis.Cmdable, limit int64, window time.Duration) (*FixedWindowLimiter, error) {
+	if err := checkWindow(limit, window); err != nil {
+		return nil, err
+	}
+	return &FixedWindowLimiter{
+		client: client,
+		limit:  limit,
+		window: window,
+	}, nil
+}
+
+func (l *FixedWindowLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+func (l *FixedWindowLimiter) AllowN(ctx context.Context, key string, n int64) (Result, error) {
+	if err := checkN(n, l.limit); err != nil {
+		return Result{}, err
+	}
+	res, err := l.client.Eval(ctx, luaFixedWindow, []string{key},
+		l.limit, l.window.Milliseconds(), n).Result()
+	if err != nil {
+		return Result{}, err
+	}
+	return parseResult(res)
+}
@@ -0,0 +1,69 @@
+//go:build e2e
+
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter_e2e(t *testing.T) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr: "localhost:6379",
+	})
+	fixed, err := NewFixedWindowLimiter(rdb, 3, time.Second)
+	require.NoError(t, err)
+	sliding, err := NewSlidingWindowLimiter(rdb, 3, time.Second)
+	require.NoError(t, err)
+	bucket, err := NewTokenBucketLimiter(rdb, 3, 3)
+	require.NoError(t, err)
+	testCases := []struct {
+		name    string
+		limiter Limiter
+	}{
+		{
+			name:    "fixed window",
+			limiter: fixed,
+		},
+		{
+			name:    "sliding window",
+			limiter: sliding,
+		},
+		{
+			name:    "token bucket",
+			limiter: bucket,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+			defer cancel()
+			key := "ratelimit:e2e:" + tc.name
+			defer rdb.Del(ctx, key)
+
+			res, err := tc.limiter.AllowN(ctx, key, 2)
+			require.NoError(t, err)
+			assert.True(t, res.Allowed)
+			assert.Equal(t, int64(1), res.Remaining)
+			res, err = tc.limiter.Allow(ctx, key)
+			require.NoError(t, err)
+			assert.True(t, res.Allowed)
+			res, err = tc.limiter.Allow(ctx, key)
+			require.NoError(t, err)
+			assert.False(t, res.Allowed)
+			assert.Greater(t, res.RetryAfter, time.Duration(0))
+			assert.LessOrEqual(t, res.RetryAfter, time.Second)
+
+			time.Sleep(res.RetryAfter + time.Millisecond*10)
+			res, err = tc.limiter.Allow(ctx, key)
+			require.NoError(t, err)
+			assert.True(t, res.Allowed)
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/luxpo/time-go2nd/cache/redis/mocks"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter_AllowN(t *testing.T) {
+	testCases := []struct {
+		name    string
+		limiter func(client redis.Cmdable) (Limiter, error)
+		mock    func(ctrl *gomock.Controller) redis.Cmdable
+		n       int64
+
+		wantRes Result
+		wantErr error
+	}{
+		{
+			name: "fixed window allowed",
+			limiter: func(client redis.Cmdable) (Limiter, error) {
+				return NewFixedWindowLimiter(client, 10, time.Second)
+			},
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				res := redis.NewCmd(context.Background())
+				res.SetVal([]any{int64(1), int64(8), int64(0)})
+				cmd.EXPECT().Eval(gomock.Any(), luaFixedWindow, []string{"tenant-1"},
+					int64(10), int64(1000), int64(2)).Return(res)
+				return cmd
+			},
+			n:       2,
+			wantRes: Result{Allowed: true, Remaining: 8},
+		},
+		{
+			name: "fixed window limited",
+			limiter: func(client redis.Cmdable) (Limiter, error) {
+				return NewFixedWindowLimiter(client, 10, time.Second)
+			},
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				res := redis.NewCmd(context.Background())
+				res.SetVal([]any{int64(0), int64(1), int64(300)})
+				cmd.EXPECT().Eval(gomock.Any(), luaFixedWindow, []string{"tenant-1"},
+					int64(10), int64(1000), int64(2)).Return(res)
+				return cmd
+			},
+			n:       2,
+			wantRes: Result{Remaining: 1, RetryAfter: 300 * time.Millisecond},
+		},
+		{
+			name: "exceeds limit",
+			limiter: func(client redis.Cmdable) (Limiter, error) {
+				return NewFixedWindowLimiter(client, 10, time.Second)
+			},
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				return mocks.NewMockCmdable(ctrl)
+			},
+			n:       11,
+			wantErr: ErrExceedsLimit,
+		},
+		{
+			name: "zero n",
+			limiter: func(client redis.Cmdable) (Limiter, error) {
+				return NewSlidingWindowLimiter(client, 10, time.Second)
+			},
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				return mocks.NewMockCmdable(ctrl)
+			},
+			n:       0,
+			wantErr: errors.New("ratelimit: 请求的数量必须大于 0: 0"),
+		},
+		{
+			name: "negative n",
+			limiter: func(client redis.Cmdable) (Limiter, error) {
+				return NewTokenBucketLimiter(client, 100, 20)
+			},
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				return mocks.NewMockCmdable(ctrl)
+			},
+			n:       -1,
+			wantErr: errors.New("ratelimit: 请求的数量必须大于 0: -1"),
+		},
+		{
+			name: "sliding window",
+			limiter: func(client redis.Cmdable) (Limiter, error) {
+				return NewSlidingWindowLimiter(client, 10, time.Minute)
+			},
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				res := redis.NewCmd(context.Background())
+				res.SetVal([]any{int64(0), int64(0), int64(1500)})
+				cmd.EXPECT().Eval(gomock.Any(), luaSlidingWindow, []string{"tenant-1"},
+					int64(10), int64(60000), int64(1), gomock.Any()).Return(res)
+				return cmd
+			},
+			n:       1,
+			wantRes: Result{RetryAfter: 1500 * time.Millisecond},
+		},
+		{
+			name: "token bucket",
+			limiter: func(client redis.Cmdable) (Limiter, error) {
+				return NewTokenBucketLimiter(client, 100, 20)
+			},
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				res := redis.NewCmd(context.Background())
+				res.SetVal([]any{int64(1), int64(15), int64(0)})
+				cmd.EXPECT().Eval(gomock.Any(), luaTokenBucket, []string{"tenant-1"},
+					0.1, int64(20), int64(5)).Return(res)
+				return cmd
+			},
+			n:       5,
+			wantRes: Result{Allowed: true, Remaining: 15},
+		},
+		{
+			name: "redis error",
+			limiter: func(client redis.Cmdable) (Limiter, error) {
+				return NewTokenBucketLimiter(client, 100, 20)
+			},
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				res := redis.NewCmd(context.Background())
+				res.SetErr(context.DeadlineExceeded)
+				cmd.EXPECT().Eval(gomock.Any(), luaTokenBucket, []string{"tenant-1"},
+					0.1, int64(20), int64(1)).Return(res)
+				return cmd
+			},
+			n:       1,
+			wantErr: context.DeadlineExceeded,
+		},
+		{
+			name: "invalid result",
+			limiter: func(client redis.Cmdable) (Limiter, error) {
+				return NewFixedWindowLimiter(client, 10, time.Second)
+			},
+			mock: func(ctrl *gomock.Controller) redis.Cmdable {
+				cmd := mocks.NewMockCmdable(ctrl)
+				res := redis.NewCmd(context.Background())
+				res.SetVal("OK")
+				cmd.EXPECT().Eval(gomock.Any(), luaFixedWindow, []string{"tenant-1"},
+					int64(10), int64(1000), int64(1)).Return(res)
+				return cmd
+			},
+			n:       1,
+			wantErr: errors.New("ratelimit: 非法的返回值 OK"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			l, err := tc.limiter(tc.mock(ctrl))
+			require.NoError(t, err)
+			res, err := l.AllowN(context.Background(), "tenant-1", tc.n)
+			if tc.wantErr != nil {
+				assert.EqualError(t, err, tc.wantErr.Error())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantRes, res)
+		})
+	}
+}
+
+func TestNewLimiter(t *testing.T) {
+	testCases := []struct {
+		name    string
+		newFunc func() (Limiter, error)
+		wantErr error
+	}{
+		{
+			name: "fixed window zero limit",
+			newFunc: func() (Limiter, error) {
+				return NewFixedWindowLimiter(nil, 0, time.Second)
+			},
+			wantErr: errInvalidArgument,
+		},
+		{
+			// 脚本里面按照毫秒计算，会变成 0
+			name: "fixed window sub-millisecond",
+			newFunc: func() (Limiter, error) {
+				return NewFixedWindowLimiter(nil, 10, time.Microsecond)
+			},
+			wantErr: errInvalidArgument,
+		},
+		{
+			name: "sliding window negative window",
+			newFunc: func() (Limiter, error) {
+				return NewSlidingWindowLimiter(nil, 10, -time.Second)
+			},
+			wantErr: errInvalidArgument,
+		},
+		{
+			name: "token bucket zero rate",
+			newFunc: func() (Limiter, error) {
+				return NewTokenBucketLimiter(nil, 0, 10)
+			},
+			wantErr: errInvalidArgument,
+		},
+		{
+			name: "token bucket NaN rate",
+			newFunc: func() (Limiter, error) {
+				return NewTokenBucketLimiter(nil, math.NaN(), 10)
+			},
+			wantErr: errInvalidArgument,
+		},
+		{
+			name: "token bucket zero burst",
+			newFunc: func() (Limiter, error) {
+				return NewTokenBucketLimiter(nil, 10, 0)
+			},
+			wantErr: errInvalidArgument,
+		},
+		{
+			name: "token bucket",
+			newFunc: func() (Limiter, error) {
+				return NewTokenBucketLimiter(nil, 0.5, 1)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.newFunc()
+			assert.ErrorIs(t, err, tc.wantErr)
+		})
+	}
+}
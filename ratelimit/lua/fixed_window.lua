@@ -0,0 +1,18 @@
+-- KEYS[1] 计数器
+-- ARGV[1] 窗口内最多多少个请求，ARGV[2] 窗口大小（毫秒），ARGV[3] 这一次要多少个
+local limit = tonumber(ARGV[1])
+local n = tonumber(ARGV[3])
+local cnt = tonumber(redis.call("get", KEYS[1]) or "0")
+if cnt + n > limit then
+    local ttl = redis.call("pttl", KEYS[1])
+    if ttl < 0 then
+        ttl = tonumber(ARGV[2])
+    end
+    return {0, limit - cnt, ttl}
+end
+cnt = redis.call("incrby", KEYS[1], n)
+-- 新的窗口，或者之前设置过期时间失败了
+if redis.call("pttl", KEYS[1]) < 0 then
+    redis.call("pexpire", KEYS[1], ARGV[2])
+end
+return {1, limit - cnt, 0}
@@ -0,0 +1,22 @@
+-- KEYS[1] 保存请求时间的 zset
+-- ARGV[1] 窗口内最多多少个请求，ARGV[2] 窗口大小（毫秒），ARGV[3] 这一次要多少个，
+-- ARGV[4] 这一次请求的唯一标识，用来生成 zset 的 member
+-- 用 redis 的时间，避免不同实例的时钟不一致，需要 redis 5 以上
+local t = redis.call("time")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+redis.call("zremrangebyscore", KEYS[1], "-inf", now - window)
+local cnt = redis.call("zcard", KEYS[1])
+if cnt + n > limit then
+    -- 要等最早的 cnt + n - limit 个请求滑出窗口
+    local idx = cnt + n - limit - 1
+    local oldest = redis.call("zrange", KEYS[1], idx, idx, "withscores")
+    return {0, limit - cnt, tonumber(oldest[2]) + window - now}
+end
+for i = 1, n do
+    redis.call("zadd", KEYS[1], now, ARGV[4] .. ":" .. i)
+end
+redis.call("pexpire", KEYS[1], window)
+return {1, limit - cnt - n, 0}
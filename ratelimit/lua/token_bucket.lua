@@ -0,0 +1,28 @@
+-- KEYS[1] 保存令牌数和上一次更新时间的 hash
+-- ARGV[1] 每毫秒生成多少个令牌，ARGV[2] 桶的容量，ARGV[3] 这一次要多少个
+-- 用 redis 的时间，避免不同实例的时钟不一致，需要 redis 5 以上
+local t = redis.call("time")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+local data = redis.call("hmget", KEYS[1], "tokens", "ts")
+local tokens = tonumber(data[1])
+local ts = tonumber(data[2])
+if tokens == nil then
+    tokens = burst
+    ts = now
+end
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+local retry = 0
+if tokens >= n then
+    tokens = tokens - n
+    allowed = 1
+else
+    retry = math.ceil((n - tokens) / rate)
+end
+redis.call("hset", KEYS[1], "tokens", tokens, "ts", now)
+-- 桶满了之后 key 就没有意义了，直接过期
+redis.call("pexpire", KEYS[1], math.ceil(burst / rate) + 1)
+return {allowed, math.floor(tokens), retry}
@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/rand"
+	_ "embed"
+	"encoding/hex"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	_ Limiter = (*SlidingWindowLimiter)(nil)
+
+	//go:embed lua/sliding_window.lua
+	luaSlidingWindow string
+)
+
+// SlidingWindowLimiter 滑动窗口，任意 window 时间内最多 limit 个请求。
+// 每个请求在 zset 里面占一个元素，limit 很大的时候比较占内存
+type SlidingWindowLimiter struct {
+	client redis.Cmdable
+	limit  int64
+	window time.Duration
+}
+
+// NewSlidingWindowLimiter limit 必须大于 0，window 至少一毫秒
+func NewSlidingWindowLimiter(client redis.Cmdable, limit int64, window time.Duration) (*SlidingWindowLimiter, error) {
+	if err := checkWindow(limit, window); err != nil {
+		return nil, err
+	}
+	return &SlidingWindowLimiter{
+		client: client,
+		limit:  limit,
+		window: window,
+	}, nil
+}
+
+func (l *SlidingWindowLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+func (l *SlidingWindowLimiter) AllowN(ctx context.Context, key string, n int64) (Result, error) {
+	if err := checkN(n, l.limit); err != nil {
+		return Result{}, err
+	}
+	// 同一毫秒里面的请求 score 一样，需要一个唯一的 member
+	bs := make([]byte, 8)
+	if _, err := rand.Read(bs); err != nil {
+		return Result{}, err
+	}
+	res, err := l.client.Eval(ctx, luaSlidingWindow, []string{key},
+		l.limit, l.window.Milliseconds(), n, hex.EncodeToString(bs)).Result()
+	if err != nil {
+		return Result{}, err
+	}
+	return parseResult(res)
+}
@@ -0,0 +1,58 @@
+package ratelimit
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"math"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	_ Limiter = (*TokenBucketLimiter)(nil)
+
+	//go:embed lua/token_bucket.lua
+	luaTokenBucket string
+)
+
+// TokenBucketLimiter 令牌桶，每秒生成 rate 个令牌，最多攒 burst 个。
+// 允许短时间的突发流量，长期来看速率不超过 rate
+type TokenBucketLimiter struct {
+	client redis.Cmdable
+	rate   float64
+	burst  int64
+}
+
+// NewTokenBucketLimiter rate 和 burst 都必须大于 0
+func NewTokenBucketLimiter(client redis.Cmdable, rate float64, burst int64) (*TokenBucketLimiter, error) {
+	// 脚本里面会除以 rate，取反是为了把 NaN 也拦下来
+	if !(rate > 0) || math.IsInf(rate, 1) {
+		return nil, fmt.Errorf("%w: rate %v", errInvalidArgument, rate)
+	}
+	if burst <= 0 {
+		return nil, fmt.Errorf("%w: burst %d", errInvalidArgument, burst)
+	}
+	return &TokenBucketLimiter{
+		client: client,
+		rate:   rate,
+		burst:  burst,
+	}, nil
+}
+
+func (l *TokenBucketLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+func (l *TokenBucketLimiter) AllowN(ctx context.Context, key string, n int64) (Result, error) {
+	if err := checkN(n, l.burst); err != nil {
+		return Result{}, err
+	}
+	// 脚本里面按照毫秒计算
+	res, err := l.client.Eval(ctx, luaTokenBucket, []string{key},
+		l.rate/1000, l.burst, n).Result()
+	if err != nil {
+		return Result{}, err
+	}
+	return parseResult(res)
+}
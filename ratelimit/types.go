@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	// ErrExceedsLimit 一次要的数量比上限还多，永远不可能通过
+	ErrExceedsLimit = errors.New("ratelimit: 请求的数量超过了上限")
+	// ErrInvalidN AllowN 的 n 必须大于 0
+	ErrInvalidN = errors.New("ratelimit: 请求的数量必须大于 0")
+
+	errInvalidArgument = errors.New("ratelimit: 参数不合法")
+)
+
+// Limiter 基于 redis 的限流器，多个实例用同一个 key 共享配额。
+// 每一次检查都是一个 lua 脚本，是原子的
+type Limiter interface {
+	// Allow 等价于 AllowN(ctx, key, 1)
+	Allow(ctx context.Context, key string) (Result, error)
+	// AllowN n 小于等于 0 的时候返回 ErrInvalidN
+	AllowN(ctx context.Context, key string, n int64) (Result, error)
+}
+
+type Result struct {
+	Allowed bool
+	// Remaining 剩余的配额
+	Remaining int64
+	// RetryAfter 被限流的时候，至少要等多久才可能通过
+	RetryAfter time.Duration
+}
+
+// parseResult 脚本返回 {是否通过, 剩余配额, 重试间隔（毫秒）}
+func parseResult(res any) (Result, error) {
+	vals, ok := res.([]any)
+	if !ok || len(vals) != 3 {
+		return Result{}, fmt.Errorf("ratelimit: 非法的返回值 %v", res)
+	}
+	ints := make([]int64, 0, 3)
+	for _, val := range vals {
+		i, ok := val.(int64)
+		if !ok {
+			return Result{}, fmt.Errorf("ratelimit: 非法的返回值 %v", res)
+		}
+		ints = append(ints, i)
+	}
+	return Result{
+		Allowed:    ints[0] == 1,
+		Remaining:  ints[1],
+		RetryAfter: time.Duration(ints[2]) * time.Millisecond,
+	}, nil
+}
+
+// checkN 检查 AllowN 的 n，limit 是一次最多能要多少个
+func checkN(n, limit int64) error {
+	if n <= 0 {
+		return fmt.Errorf("%w: %d", ErrInvalidN, n)
+	}
+	if n > limit {
+		return ErrExceedsLimit
+	}
+	return nil
+}
+
+// checkWindow 脚本里面按照毫秒计算，窗口至少要一毫秒
+func checkWindow(limit int64, window time.Duration) error {
+	if limit <= 0 {
+		return fmt.Errorf("%w: limit %d", errInvalidArgument, limit)
+	}
+	if window < time.Millisecond {
+		return fmt.Errorf("%w: window %v", errInvalidArgument, window)
+	}
+	return nil
+}